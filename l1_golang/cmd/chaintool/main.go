@@ -0,0 +1,180 @@
+// Command chaintool works on a node's chain database while the node is
+// stopped: it exports canonical blocks to a compressed file, imports them
+// into another datadir and verifies a chain by re-executing it.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"nusa-chain/internal/blockchain"
+	"nusa-chain/internal/storage"
+)
+
+const usage = `usage: chaintool <command> [flags] <file>
+
+commands:
+  export   write canonical blocks to a compressed export file
+  import   add the blocks of an export file to the chain
+  verify   re-execute the datadir chain, or an export file, from genesis
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "export":
+		exportCmd(os.Args[2:])
+	case "import":
+		importCmd(os.Args[2:])
+	case "verify":
+		verifyCmd(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// chainFlags are shared by every command that opens a datadir
+type chainFlags struct {
+	datadir *string
+	genesis *string
+}
+
+func newChainFlags(fs *flag.FlagSet) chainFlags {
+	return chainFlags{
+		datadir: fs.String("datadir", "./data/chaindata", "chain database directory"),
+		genesis: fs.String("genesis", "config/genesis.json", "genesis file the datadir was created with"),
+	}
+}
+
+func (f chainFlags) open() (*blockchain.ChainManager, error) {
+	genesis, err := blockchain.LoadGenesis(*f.genesis)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load genesis: %v", err)
+	}
+	config, err := genesis.ChainConfig()
+	if err != nil {
+		return nil, fmt.Errorf("invalid genesis: %v", err)
+	}
+	db, err := storage.Open("leveldb", *f.datadir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open chain database: %v", err)
+	}
+	chainManager, err := blockchain.NewChainManager(config, db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return chainManager, nil
+}
+
+func exportCmd(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	chain := newChainFlags(fs)
+	from := fs.Uint64("from", 0, "first block height")
+	to := fs.Int64("to", -1, "last block height (default: head)")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("export: expected one output file")
+	}
+
+	chainManager, err := chain.open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer chainManager.Close()
+
+	last := chainManager.GetLatestBlock().Header.Height
+	if *to >= 0 {
+		last = uint64(*to)
+	}
+
+	file, err := os.Create(fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	count, err := chainManager.ExportBlocks(file, *from, last)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		log.Fatalf("export failed after %d blocks: %v", count, err)
+	}
+	fmt.Printf("📦 Exported %d blocks (#%d-#%d) to %s\n", count, *from, last, fs.Arg(0))
+}
+
+func importCmd(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	chain := newChainFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		log.Fatal("import: expected one export file")
+	}
+
+	chainManager, err := chain.open()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer chainManager.Close()
+
+	file, err := os.Open(fs.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	count, err := chainManager.ImportBlocks(file)
+	fmt.Printf("📥 Imported %d blocks, head #%d\n", count, chainManager.GetLatestBlock().Header.Height)
+	if err != nil {
+		fmt.Printf("❌ Import stopped: %v\n", err)
+		chainManager.Close()
+		os.Exit(1)
+	}
+}
+
+func verifyCmd(args []string) {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	chain := newChainFlags(fs)
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		log.Fatal("verify: expected at most one export file")
+	}
+
+	genesis, err := blockchain.LoadGenesis(*chain.genesis)
+	if err != nil {
+		log.Fatal("Failed to load genesis:", err)
+	}
+	config, err := genesis.ChainConfig()
+	if err != nil {
+		log.Fatal("Invalid genesis:", err)
+	}
+
+	var verifier *blockchain.ChainVerifier
+	var verifyErr error
+	if fs.NArg() == 1 {
+		file, err := os.Open(fs.Arg(0))
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+		verifier, verifyErr = blockchain.VerifyExport(file, config)
+	} else {
+		db, err := storage.Open("leveldb", *chain.datadir)
+		if err != nil {
+			log.Fatal("Failed to open chain database:", err)
+		}
+		defer db.Close()
+		verifier, verifyErr = blockchain.VerifyDatabase(db, config)
+	}
+	if verifyErr != nil {
+		fmt.Printf("❌ Verification failed: %v\n", verifyErr)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Verified %d blocks, head #%d, supply %s wei\n", verifier.Height()+1, verifier.Height(), verifier.Supply().Total)
+}
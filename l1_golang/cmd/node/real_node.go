@@ -5,11 +5,15 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	
+	"nusa-chain/internal/api"
 	"nusa-chain/internal/blockchain"
 	"nusa-chain/internal/consensus"
+	"nusa-chain/internal/node"
 	"nusa-chain/internal/p2p"
+	"nusa-chain/internal/storage"
 	"nusa-chain/internal/wallet"
 )
 
@@ -18,7 +22,10 @@ func main() {
 	fmt.Println("======================================")
 	
 	// Load configuration
-	config := loadConfig()
+	config, err := node.LoadConfig("config/node.yaml")
+	if err != nil {
+		log.Fatal("Failed to load config:", err)
+	}
 	
 	// Initialize wallet
 	w, err := wallet.NewWallet()
@@ -27,36 +34,44 @@ func main() {
 	}
 	fmt.Printf("👛 Node Wallet: %s\n", w.Address.Hex())
 	
-	// Initialize blockchain
-	chainConfig := blockchain.ChainConfig{
-		ChainID:    2024,
-		BlockTime:  5,
-		Difficulty: 1000000,
-		MaxGasLimit: 8000000,
-		MinGasPrice: 1000000000, // 1 gwei
-		BlockReward: 2000000000000000000, // 2 NUSA
-		GenesisAccounts: []blockchain.GenesisAccount{
-			{
-				Address: w.Address.Hex(),
-				Balance: 100000000000000000000, // 100 NUSA
-				Stake:   10000000000000000000,  // 10 NUSA
-			},
-		},
+	// Load genesis
+	genesis, err := blockchain.LoadGenesis("config/genesis.json")
+	if err != nil {
+		log.Fatal("Failed to load genesis:", err)
+	}
+	chainConfig, err := genesis.ChainConfig()
+	if err != nil {
+		log.Fatal("Invalid genesis:", err)
 	}
 	
-	chainManager, err := blockchain.NewChainManager(chainConfig)
+	// Open chain database
+	db, err := storage.Open(config.Database.Type, config.Database.Path)
 	if err != nil {
-		log.Fatal("Failed to initialize blockchain:", err)
+		log.Fatal("Failed to open chain database:", err)
 	}
 	
-	// Initialize PoVC consensus
-	povc := consensus.NewPoVCReal(chainManager, "http://localhost:8000")
+	chainManager, err := blockchain.NewChainManager(chainConfig, db)
+	if err != nil {
+		log.Fatal("Failed to initialize blockchain:", err)
+	}
+	fmt.Printf("🧬 Genesis: %s (chain %d)\n", chainManager.GenesisHash(), chainConfig.ChainID)
 	
-	// Register as validator
-	if err := povc.RegisterValidator(w.Address.Hex(), 10000000000000000000); err != nil {
-		fmt.Printf("⚠️  Failed to register as validator: %v\n", err)
+	// Restore pending transactions from the last run, kept beside the chain data
+	journalPath := filepath.Join(filepath.Dir(config.Database.Path), "transactions.rlp")
+	if err := chainManager.OpenTxJournal(journalPath); err != nil {
+		log.Fatal("Failed to open transaction journal:", err)
 	}
 	
+	// Start HTTP API
+	go func() {
+		if err := api.NewServer(chainManager).Start(":8545"); err != nil {
+			fmt.Printf("⚠️  API server stopped: %v\n", err)
+		}
+	}()
+	
+	// Initialize PoVC consensus
+	povc := consensus.NewPoVCReal(chainManager, w, "http://localhost:8000")
+	
 	// Initialize P2P network
 	p2pNetwork, err := p2p.NewP2PNetwork(30303, []string{
 		"/ip4/127.0.0.1/tcp/30303/p2p/12D3KooWTest", //
\ No newline at end of file
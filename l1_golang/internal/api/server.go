@@ -0,0 +1,338 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"nusa-chain/internal/blockchain"
+)
+
+// Page size of address history
+const (
+	defaultPageSize = 25
+	maxPageSize     = 100
+)
+
+// Server exposes chain data over HTTP for wallets and the frontend.
+// Responses use the same {"success", "data"} envelope as the AI engine.
+type Server struct {
+	chainManager *blockchain.ChainManager
+	mux          *http.ServeMux
+}
+
+type response struct {
+	Success bool        `json:"success"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+func NewServer(chainManager *blockchain.ChainManager) *Server {
+	s := &Server{
+		chainManager: chainManager,
+		mux:          http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("/", s.handleStatus)
+	s.mux.HandleFunc("/receipt/", s.handleReceipt)
+	s.mux.HandleFunc("/fees", s.handleFees)
+	s.mux.HandleFunc("/proof/", s.handleProof)
+	s.mux.HandleFunc("/account/", s.handleAccount)
+	s.mux.HandleFunc("/block/", s.handleBlock)
+	s.mux.HandleFunc("/tx/", s.handleTransaction)
+	s.mux.HandleFunc("/address/", s.handleAddressTxs)
+	s.mux.HandleFunc("/supply", s.handleSupply)
+	s.mux.HandleFunc("/validators", s.handleValidators)
+	s.mux.HandleFunc("/validators/", s.handleValidator)
+	s.mux.HandleFunc("/delegations/", s.handleDelegations)
+	s.mux.HandleFunc("/finalized", s.handleFinalized)
+
+	return s
+}
+
+// Start serves the API on addr; it blocks like http.ListenAndServe
+func (s *Server) Start(addr string) error {
+	fmt.Printf("🌐 API server listening on %s\n", addr)
+	return http.ListenAndServe(addr, s)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	latest := s.chainManager.GetLatestBlock()
+	finalized := s.chainManager.FinalizedBlock()
+	pending, queued := s.chainManager.TxPoolStatus()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"genesis_hash":     s.chainManager.GenesisHash(),
+		"height":           latest.Header.Height,
+		"head":             latest.Hash(),
+		"finalized_height": finalized.Header.Height,
+		"finalized_hash":   finalized.Hash(),
+		"pending_txs":      pending,
+		"queued_txs":       queued,
+	})
+}
+
+// GET /receipt/{txHash}
+func (s *Server) handleReceipt(w http.ResponseWriter, r *http.Request) {
+	txHash := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/receipt/"), "0x")
+	if txHash == "" {
+		writeError(w, http.StatusBadRequest, "missing transaction hash")
+		return
+	}
+
+	receipt, err := s.chainManager.GetReceipt(txHash)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, receipt)
+}
+
+// GET /proof/{txHash}[?block={blockHash}]: merkle branch of a transaction,
+// in the canonical block holding it unless a block is given
+func (s *Server) handleProof(w http.ResponseWriter, r *http.Request) {
+	txHash := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/proof/"), "0x")
+	if txHash == "" {
+		writeError(w, http.StatusBadRequest, "missing transaction hash")
+		return
+	}
+	blockHash := strings.TrimPrefix(r.URL.Query().Get("block"), "0x")
+
+	proof, err := s.chainManager.GetTransactionProof(blockHash, txHash)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, proof)
+}
+
+// GET /account/{address}[?height={n}]: balance, nonce and stake at a
+// canonical height (the head by default) with a proof against its state root
+func (s *Server) handleAccount(w http.ResponseWriter, r *http.Request) {
+	address := strings.TrimPrefix(r.URL.Path, "/account/")
+	if address == "" {
+		writeError(w, http.StatusBadRequest, "missing address")
+		return
+	}
+
+	height := s.chainManager.GetLatestBlock().Header.Height
+	if param := r.URL.Query().Get("height"); param != "" {
+		parsed, err := strconv.ParseUint(param, 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid height")
+			return
+		}
+		height = parsed
+	}
+
+	proof, err := s.chainManager.GetAccountProof(address, height)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, proof)
+}
+
+// GET /block/{hash|height}
+func (s *Server) handleBlock(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/block/"), "0x")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "missing block hash or height")
+		return
+	}
+
+	// Heights are short decimals, hashes 64 hex characters
+	var block *blockchain.Block
+	var err error
+	if height, parseErr := strconv.ParseUint(id, 10, 64); parseErr == nil && len(id) < 64 {
+		block, err = s.chainManager.GetBlockByHeight(height)
+	} else {
+		block, err = s.chainManager.GetBlockByHash(id)
+	}
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	// Blocks up to the finalized height are final on the canonical chain
+	canonical, err := s.chainManager.GetBlockByHeight(block.Header.Height)
+	final := err == nil && canonical.Hash() == block.Hash() &&
+		block.Header.Height <= s.chainManager.FinalizedHeight()
+	data := map[string]interface{}{
+		"hash":      block.Hash(),
+		"block":     block,
+		"finalized": final,
+	}
+	if justification, err := s.chainManager.GetJustification(block.Hash()); err == nil {
+		data["justification"] = justification
+	}
+	writeJSON(w, http.StatusOK, data)
+}
+
+// GET /tx/{txHash}
+func (s *Server) handleTransaction(w http.ResponseWriter, r *http.Request) {
+	txHash := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/tx/"), "0x")
+	if txHash == "" {
+		writeError(w, http.StatusBadRequest, "missing transaction hash")
+		return
+	}
+
+	tx, lookup, err := s.chainManager.GetTransaction(txHash)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"transaction":  tx,
+		"block_hash":   lookup.BlockHash,
+		"block_height": lookup.BlockHeight,
+		"index":        lookup.Index,
+	})
+}
+
+// GET /address/{address}/txs[?cursor={cursor}&limit={n}]: transaction
+// history, newest first; next_cursor is empty on the last page
+func (s *Server) handleAddressTxs(w http.ResponseWriter, r *http.Request) {
+	address := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/address/"), "/txs")
+	if address == "" || !strings.HasSuffix(r.URL.Path, "/txs") {
+		writeError(w, http.StatusNotFound, "not found")
+		return
+	}
+
+	limit := defaultPageSize
+	if param := r.URL.Query().Get("limit"); param != "" {
+		parsed, err := strconv.Atoi(param)
+		if err != nil || parsed <= 0 {
+			writeError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		if parsed < maxPageSize {
+			limit = parsed
+		} else {
+			limit = maxPageSize
+		}
+	}
+
+	txs, next, err := s.chainManager.GetAddressTxs(address, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"transactions": txs,
+		"next_cursor":  next,
+	})
+}
+
+// GET /supply[?height={n}]: minted, burned, staked and circulating NUSA
+// after the head block, or after the canonical block at height
+func (s *Server) handleSupply(w http.ResponseWriter, r *http.Request) {
+	param := r.URL.Query().Get("height")
+	if param == "" {
+		writeJSON(w, http.StatusOK, s.chainManager.GetSupply())
+		return
+	}
+
+	height, err := strconv.ParseUint(param, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid height")
+		return
+	}
+	supply, err := s.chainManager.GetSupplyAt(height)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, supply)
+}
+
+// GET /validators: registered validators with stake, sorted by address
+func (s *Server) handleValidators(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.chainManager.GetValidators())
+}
+
+// GET /validators/{address}: a validator, its commission and its
+// delegations with the rewards each delegator has accrued
+func (s *Server) handleValidator(w http.ResponseWriter, r *http.Request) {
+	address := strings.TrimPrefix(r.URL.Path, "/validators/")
+	if address == "" {
+		writeError(w, http.StatusBadRequest, "missing address")
+		return
+	}
+
+	validator, delegations, err := s.chainManager.GetValidator(address)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"validator":   validator,
+		"delegations": delegations,
+	})
+}
+
+// GET /delegations/{address}: stake address delegated and the rewards it
+// can claim, per validator
+func (s *Server) handleDelegations(w http.ResponseWriter, r *http.Request) {
+	address := strings.TrimPrefix(r.URL.Path, "/delegations/")
+	if address == "" {
+		writeError(w, http.StatusBadRequest, "missing address")
+		return
+	}
+
+	delegations := s.chainManager.GetDelegations(address)
+	rewards := blockchain.Amount{}
+	for _, delegation := range delegations {
+		rewards, _ = rewards.Add(delegation.Rewards)
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"delegations": delegations,
+		"rewards":     rewards,
+	})
+}
+
+// GET /finalized: the last finalized block and the justification that
+// finalized it; genesis is final without one
+func (s *Server) handleFinalized(w http.ResponseWriter, r *http.Request) {
+	block := s.chainManager.FinalizedBlock()
+	data := map[string]interface{}{
+		"height": block.Header.Height,
+		"hash":   block.Hash(),
+	}
+	if justification, err := s.chainManager.GetJustification(block.Hash()); err == nil {
+		data["justification"] = justification
+	}
+	writeJSON(w, http.StatusOK, data)
+}
+
+// GET /fees: base fee of the next block and suggested dynamic fee fields
+func (s *Server) handleFees(w http.ResponseWriter, r *http.Request) {
+	maxFee, priorityFee := s.chainManager.SuggestFees()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"base_fee":                 s.chainManager.NextBaseFee(),
+		"max_fee_per_gas":          maxFee,
+		"max_priority_fee_per_gas": priorityFee,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response{Success: true, Data: data})
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response{Success: false, Error: message})
+}
@@ -0,0 +1,118 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+
+	"nusa-chain/internal/trie"
+)
+
+// AccountProof is an account's consensus state at a canonical block, with
+// the state trie nodes that prove it against the block's StateRoot
+type AccountProof struct {
+	Address     string       `json:"address"`
+	BlockHeight uint64       `json:"block_height"`
+	BlockHash   string       `json:"block_hash"`
+	StateRoot   string       `json:"state_root"`
+	Balance     Amount       `json:"balance"`
+	Nonce       uint64       `json:"nonce"`
+	Stake       Amount       `json:"stake"`
+	Validator   bool         `json:"validator"`
+	Unbonding   []Unbonding  `json:"unbonding,omitempty"`
+	Commission  uint16       `json:"commission,omitempty"`
+	Delegations []Delegation `json:"delegations,omitempty"`
+	NVSScore    uint16       `json:"nvs_score,omitempty"`
+	Proof       []string     `json:"proof"` // hex-encoded trie nodes, root first
+}
+
+// GetAccountProof returns the balance, nonce and stake of address as of the
+// canonical block at height. Committed state roots are never pruned, so any
+// height up to the head can be answered.
+func (cm *ChainManager) GetAccountProof(address string, height uint64) (*AccountProof, error) {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	address = CanonicalAddress(address)
+	if height >= uint64(len(cm.Chain)) {
+		return nil, fmt.Errorf("block %d not found", height)
+	}
+	block := cm.Chain[height]
+
+	stateTrie, err := trie.New(block.Header.StateRoot, cm.db)
+	if err != nil {
+		return nil, fmt.Errorf("state of block %d unavailable: %v", height, err)
+	}
+	key := stateKey(address)
+	nodes, err := stateTrie.Prove(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prove account %s: %v", address, err)
+	}
+	data, err := stateTrie.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	account, err := decodeAccount(data)
+	if err != nil {
+		return nil, err
+	}
+
+	proof := &AccountProof{
+		Address:     address,
+		BlockHeight: height,
+		BlockHash:   block.Hash(),
+		StateRoot:   block.Header.StateRoot,
+		Balance:     account.Balance,
+		Nonce:       account.Nonce,
+		Stake:       account.Stake,
+		Validator:   account.Validator,
+		Unbonding:   account.Unbonding,
+		Commission:  account.Commission,
+		Delegations: account.Delegations,
+		NVSScore:    account.NVSScore,
+		Proof:       make([]string, len(nodes)),
+	}
+	for i, node := range nodes {
+		proof.Proof[i] = hex.EncodeToString(node)
+	}
+	return proof, nil
+}
+
+// VerifyAccountProof checks the account fields of proof against stateRoot,
+// normally the StateRoot of a header the caller already trusts. An account
+// the trie does not hold must be reported with zero fields.
+func VerifyAccountProof(proof *AccountProof, stateRoot string) error {
+	nodes := make([][]byte, len(proof.Proof))
+	for i, node := range proof.Proof {
+		data, err := hex.DecodeString(node)
+		if err != nil {
+			return fmt.Errorf("invalid proof node %d: %v", i, err)
+		}
+		nodes[i] = data
+	}
+
+	data, err := trie.VerifyProof(stateRoot, stateKey(proof.Address), nodes)
+	if err != nil {
+		return err
+	}
+	account, err := decodeAccount(data)
+	if err != nil {
+		return err
+	}
+
+	// Compare in trie encoding so every consensus field is covered
+	claimed := encodeAccount(AccountState{
+		Balance:     proof.Balance,
+		Nonce:       proof.Nonce,
+		Stake:       proof.Stake,
+		Validator:   proof.Validator,
+		Unbonding:   proof.Unbonding,
+		Commission:  proof.Commission,
+		Delegations: proof.Delegations,
+		NVSScore:    proof.NVSScore,
+	})
+	if !bytes.Equal(claimed, encodeTrieAccount(account)) {
+		return fmt.Errorf("account %s does not match the proven state", proof.Address)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package blockchain
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAccountProofs(t *testing.T) {
+	c := newTestChain(t, 2)
+	recipient := keyAddress(newTestKey(t))
+	c.mine(c.transfer(0, 0, recipient, NUSA(3)))
+	c.mine(c.transfer(0, 1, recipient, NUSA(4)))
+
+	tests := []struct {
+		name    string
+		address string
+		height  uint64
+		balance Amount
+	}{
+		{"recipient before it existed", recipient, 0, Amount{}},
+		{"recipient after the first transfer", recipient, 1, NUSA(3)},
+		{"recipient at the head", recipient, 2, NUSA(7)},
+		{"lowercase address", strings.ToLower(recipient), 2, NUSA(7)},
+		{"untouched account", c.addrs[1], 2, NUSA(100)},
+	}
+	for _, test := range tests {
+		proof, err := c.cm.GetAccountProof(test.address, test.height)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		block, _ := c.cm.GetBlockByHeight(test.height)
+		if proof.Balance != test.balance || proof.BlockHash != block.Hash() || proof.StateRoot != block.Header.StateRoot {
+			t.Errorf("%s: balance %s at %s, want %s at %s", test.name, proof.Balance, proof.BlockHash, test.balance, block.Hash())
+		}
+		if err := VerifyAccountProof(proof, block.Header.StateRoot); err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+	}
+
+	// The sender's nonce and balance at block 1, altered one field at a time
+	head := c.cm.GetLatestBlock()
+	block1, _ := c.cm.GetBlockByHeight(1)
+	tampered := []struct {
+		name string
+		edit func(proof *AccountProof) string // returns the root to check against
+	}{
+		{"balance", func(proof *AccountProof) string {
+			proof.Balance, _ = proof.Balance.Add(NewAmount(1))
+			return block1.Header.StateRoot
+		}},
+		{"nonce", func(proof *AccountProof) string {
+			proof.Nonce++
+			return block1.Header.StateRoot
+		}},
+		{"stake", func(proof *AccountProof) string {
+			proof.Stake = NUSA(1)
+			return block1.Header.StateRoot
+		}},
+		{"address", func(proof *AccountProof) string {
+			proof.Address = c.addrs[1]
+			return block1.Header.StateRoot
+		}},
+		{"root of another block", func(proof *AccountProof) string {
+			return head.Header.StateRoot
+		}},
+		{"missing node", func(proof *AccountProof) string {
+			proof.Proof = proof.Proof[:len(proof.Proof)-1]
+			return block1.Header.StateRoot
+		}},
+	}
+	for _, tamper := range tampered {
+		proof, err := c.cm.GetAccountProof(c.addrs[0], 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if proof.Nonce != 1 {
+			t.Fatalf("sender nonce %d at block 1, want 1", proof.Nonce)
+		}
+		if err := VerifyAccountProof(proof, tamper.edit(proof)); err == nil {
+			t.Errorf("proof with altered %s accepted", tamper.name)
+		}
+	}
+
+	if _, err := c.cm.GetAccountProof(recipient, head.Header.Height+1); err == nil {
+		t.Error("proof above the head")
+	}
+}
@@ -0,0 +1,19 @@
+package blockchain
+
+import "github.com/ethereum/go-ethereum/common"
+
+// CanonicalAddress returns the checksummed (EIP-55) spelling of a hex
+// address, the one state is keyed by, and anything else unchanged. Queries
+// may spell an address in any case and still reach its account.
+func CanonicalAddress(address string) string {
+	if !common.IsHexAddress(address) {
+		return address
+	}
+	return common.HexToAddress(address).Hex()
+}
+
+// Transactions must spell addresses canonically: their fields are signed
+// as sent, so a second spelling would be a second account
+func isCanonicalAddress(address string) bool {
+	return common.IsHexAddress(address) && CanonicalAddress(address) == address
+}
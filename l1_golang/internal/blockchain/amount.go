@@ -0,0 +1,179 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/holiman/uint256"
+)
+
+var (
+	ErrAmountOverflow  = errors.New("amount overflows 256 bits")
+	ErrAmountUnderflow = errors.New("amount underflow")
+)
+
+// 1 NUSA = 10^18 wei
+const WeiPerNUSA = 1000000000000000000
+
+// Amount is a 256-bit unsigned token quantity in wei. In JSON it is a
+// decimal string; decimal or 0x-prefixed hex strings are accepted on input,
+// the same as config/genesis.json.
+type Amount uint256.Int
+
+func NewAmount(wei uint64) Amount {
+	var a Amount
+	a.int().SetUint64(wei)
+	return a
+}
+
+// NUSA converts whole tokens to wei
+func NUSA(tokens uint64) Amount {
+	a, _ := NewAmount(tokens).MulUint64(WeiPerNUSA)
+	return a
+}
+
+// ParseAmount parses a decimal or 0x-prefixed hex wei string
+func ParseAmount(s string) (Amount, error) {
+	var a Amount
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		// uint256 rejects leading zeros in hex, genesis files often have them
+		digits := strings.TrimLeft(s[2:], "0")
+		if digits == "" {
+			return a, nil
+		}
+		if err := a.int().SetFromHex("0x" + digits); err != nil {
+			return a, fmt.Errorf("invalid hex amount %q: %v", s, err)
+		}
+		return a, nil
+	}
+	if err := a.int().SetFromDecimal(s); err != nil {
+		return a, fmt.Errorf("invalid amount %q: %v", s, err)
+	}
+	return a, nil
+}
+
+func (a *Amount) int() *uint256.Int {
+	return (*uint256.Int)(a)
+}
+
+func (a Amount) Add(b Amount) (Amount, error) {
+	var out Amount
+	if _, overflow := out.int().AddOverflow(a.int(), b.int()); overflow {
+		return Amount{}, ErrAmountOverflow
+	}
+	return out, nil
+}
+
+func (a Amount) Sub(b Amount) (Amount, error) {
+	var out Amount
+	if _, underflow := out.int().SubOverflow(a.int(), b.int()); underflow {
+		return Amount{}, ErrAmountUnderflow
+	}
+	return out, nil
+}
+
+func (a Amount) Mul(b Amount) (Amount, error) {
+	var out Amount
+	if _, overflow := out.int().MulOverflow(a.int(), b.int()); overflow {
+		return Amount{}, ErrAmountOverflow
+	}
+	return out, nil
+}
+
+func (a Amount) MulUint64(n uint64) (Amount, error) {
+	return a.Mul(NewAmount(n))
+}
+
+// DivUint64 rounds down; dividing by zero yields zero
+func (a Amount) DivUint64(n uint64) Amount {
+	var out Amount
+	divisor := NewAmount(n)
+	out.int().Div(a.int(), divisor.int())
+	return out
+}
+
+// MulDiv returns a*n/d rounded down, without overflowing on the product
+func (a Amount) MulDiv(n, d uint64) Amount {
+	var out Amount
+	num, den := NewAmount(n), NewAmount(d)
+	if den.IsZero() {
+		return out
+	}
+	out.int().MulDivOverflow(a.int(), num.int(), den.int())
+	return out
+}
+
+// Portion returns a*part/whole rounded down; a zero whole yields zero
+func (a Amount) Portion(part, whole Amount) Amount {
+	var out Amount
+	if whole.IsZero() {
+		return out
+	}
+	out.int().MulDivOverflow(a.int(), part.int(), whole.int())
+	return out
+}
+
+func (a Amount) Cmp(b Amount) int {
+	return a.int().Cmp(b.int())
+}
+
+func (a Amount) IsZero() bool {
+	return a.int().IsZero()
+}
+
+// Uint64 reports false if the amount does not fit
+func (a Amount) Uint64() (uint64, bool) {
+	v, overflow := a.int().Uint64WithOverflow()
+	return v, !overflow
+}
+
+func (a Amount) Big() *big.Int {
+	return a.int().ToBig()
+}
+
+// Float64 is lossy; use it for display and ratios only
+func (a Amount) Float64() float64 {
+	return a.int().Float64()
+}
+
+// String returns the decimal wei value
+func (a Amount) String() string {
+	return a.int().Dec()
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+// UnmarshalJSON also takes plain JSON numbers written before amounts were strings
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var n json.Number
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("amount must be a string or number: %s", data)
+		}
+		s = n.String()
+	}
+
+	parsed, err := ParseAmount(s)
+	if err != nil {
+		return err
+	}
+	*a = parsed
+	return nil
+}
+
+func (a Amount) EncodeRLP(w io.Writer) error {
+	return rlp.Encode(w, a.int())
+}
+
+func (a *Amount) DecodeRLP(s *rlp.Stream) error {
+	return s.ReadUint256(a.int())
+}
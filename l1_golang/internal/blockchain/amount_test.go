@@ -0,0 +1,108 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// maxAmount is 2^256-1
+func maxAmount(t *testing.T) Amount {
+	t.Helper()
+	max, err := ParseAmount("0x" + "ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return max
+}
+
+func TestAmountArithmetic(t *testing.T) {
+	max := maxAmount(t)
+	tests := []struct {
+		name string
+		op   func() (Amount, error)
+		want Amount
+		err  error
+	}{
+		{"add", func() (Amount, error) { return NUSA(1).Add(NewAmount(1)) }, mustParseAmount(t, "1000000000000000001"), nil},
+		{"add up to the maximum", func() (Amount, error) {
+			below, _ := max.Sub(NewAmount(1))
+			return below.Add(NewAmount(1))
+		}, max, nil},
+		{"add overflow", func() (Amount, error) { return max.Add(NewAmount(1)) }, Amount{}, ErrAmountOverflow},
+		{"sub", func() (Amount, error) { return NewAmount(5).Sub(NewAmount(5)) }, Amount{}, nil},
+		{"sub underflow", func() (Amount, error) { return NewAmount(5).Sub(NewAmount(6)) }, Amount{}, ErrAmountUnderflow},
+		{"sub from zero", func() (Amount, error) { return Amount{}.Sub(NewAmount(1)) }, Amount{}, ErrAmountUnderflow},
+		{"mul", func() (Amount, error) { return NewAmount(3).Mul(NewAmount(7)) }, NewAmount(21), nil},
+		{"mul overflow", func() (Amount, error) { return max.Mul(NewAmount(2)) }, Amount{}, ErrAmountOverflow},
+		{"mul uint64", func() (Amount, error) { return NUSA(2).MulUint64(3) }, NUSA(6), nil},
+		{"mul uint64 by zero", func() (Amount, error) { return max.MulUint64(0) }, Amount{}, nil},
+		{"mul uint64 overflow", func() (Amount, error) { return max.DivUint64(2).MulUint64(3) }, Amount{}, ErrAmountOverflow},
+	}
+	for _, test := range tests {
+		got, err := test.op()
+		if !errors.Is(err, test.err) || got != test.want {
+			t.Errorf("%s: %s (%v), want %s (%v)", test.name, got, err, test.want, test.err)
+		}
+	}
+
+	if got := max.MulDiv(3, 4); got != mustParseAmount(t, "0x"+"bf"+"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff") {
+		t.Errorf("MulDiv past 256 bits: %s", got)
+	}
+	if got := NewAmount(10).Portion(NewAmount(1), NewAmount(3)); got != NewAmount(3) {
+		t.Errorf("Portion rounds to %s", got)
+	}
+	if got := NewAmount(10).DivUint64(0); !got.IsZero() {
+		t.Errorf("division by zero yields %s", got)
+	}
+}
+
+func mustParseAmount(t *testing.T, s string) Amount {
+	t.Helper()
+	a, err := ParseAmount(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return a
+}
+
+func TestAmountJSON(t *testing.T) {
+	max := maxAmount(t)
+	for _, a := range []Amount{{}, NewAmount(1), NUSA(1000000000), max} {
+		data, err := json.Marshal(a)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded Amount
+		if err := json.Unmarshal(data, &decoded); err != nil || decoded != a {
+			t.Errorf("%s round trips as %s to %s (%v)", a, data, decoded, err)
+		}
+	}
+
+	tests := []struct {
+		input string
+		want  Amount
+		ok    bool
+	}{
+		{`"1000000000000000000"`, NUSA(1), true},
+		{`"0x0de0b6b3a7640000"`, NUSA(1), true},
+		{`"0x"`, Amount{}, true},
+		{`1000000000000000000`, NUSA(1), true},
+		{`"-1"`, Amount{}, false},
+		{`-1`, Amount{}, false},
+		{`"1.5"`, Amount{}, false},
+		{`"1e18"`, Amount{}, false},
+		{`"ten"`, Amount{}, false},
+		{`"0xzz"`, Amount{}, false},
+		{`""`, Amount{}, false},
+		{`true`, Amount{}, false},
+		{`"115792089237316195423570985008687907853269984665640564039457584007913129639936"`, Amount{}, false}, // 2^256
+	}
+	for _, test := range tests {
+		var got Amount
+		err := json.Unmarshal([]byte(test.input), &got)
+		if (err == nil) != test.ok || (test.ok && got != test.want) {
+			t.Errorf("%s: %s (%v), want %s ok %v", test.input, got, err, test.want, test.ok)
+		}
+	}
+}
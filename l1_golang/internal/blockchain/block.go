@@ -3,8 +3,10 @@ package blockchain
 import (
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
+	"math"
 	"time"
+
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 type Block struct {
@@ -44,22 +46,54 @@ func NewBlock(index int64, transactions []Transaction, previousHash string) *Blo
 	return block
 }
 
+type legacyTxRLP struct {
+	Hash      string
+	From      string
+	To        string
+	Value     uint64
+	Gas       uint64
+	GasPrice  uint64
+	Nonce     uint64
+	Timestamp uint64
+	Data      string
+	Signature string
+}
+
+type legacyBlockRLP struct {
+	Index        uint64
+	Timestamp    uint64
+	Transactions []legacyTxRLP
+	PreviousHash string
+	Nonce        uint64
+}
+
+// Hash over the canonical encoding: version byte + RLP list. Floats are
+// encoded by their IEEE-754 bits, signed integers as two's complement.
 func (b *Block) CalculateHash() string {
-	data := struct {
-		Index        int64         `json:"index"`
-		Timestamp    int64         `json:"timestamp"`
-		Transactions []Transaction `json:"transactions"`
-		PreviousHash string        `json:"previous_hash"`
-		Nonce        int64         `json:"nonce"`
-	}{
-		Index:        b.Index,
-		Timestamp:    b.Timestamp,
-		Transactions: b.Transactions,
+	data := legacyBlockRLP{
+		Index:        uint64(b.Index),
+		Timestamp:    uint64(b.Timestamp),
+		Transactions: make([]legacyTxRLP, len(b.Transactions)),
 		PreviousHash: b.PreviousHash,
-		Nonce:        b.Nonce,
+		Nonce:        uint64(b.Nonce),
+	}
+	for i, tx := range b.Transactions {
+		data.Transactions[i] = legacyTxRLP{
+			Hash:      tx.Hash,
+			From:      tx.From,
+			To:        tx.To,
+			Value:     math.Float64bits(tx.Value),
+			Gas:       uint64(tx.Gas),
+			GasPrice:  math.Float64bits(tx.GasPrice),
+			Nonce:     uint64(tx.Nonce),
+			Timestamp: uint64(tx.Timestamp),
+			Data:      tx.Data,
+			Signature: tx.Signature,
+		}
 	}
 
-	bytes, _ := json.Marshal(data)
+	payload, _ := rlp.EncodeToBytes(data)
+	bytes := append([]byte{EncodingVersion}, payload...)
 	hash := sha256.Sum256(bytes)
 	return hex.EncodeToString(hash[:])
 }
@@ -4,25 +4,41 @@ import (
 	"sync"
 	"time"
 	"fmt"
-	"os"
-	"encoding/json"
+
+	"github.com/ethereum/go-ethereum/common/math"
+
+	"nusa-chain/internal/storage"
+	"nusa-chain/internal/trie"
 )
 
 type ChainManager struct {
 	Chain         []*Block
-	PendingTXs    []Transaction
 	State         map[string]AccountState
+	txPool        *TxPool
+	stateTrie     *trie.Trie
+	supply        *Supply // accounting after the head block
+	blocks        map[string]*Block // every known block, side branches included
+	totalDiff     map[string]uint64
+	forkChoice    ForkChoice
+	finalized     *Block // last block with a justification, never reverted
+	unbonding     map[uint64]map[string]bool // head-state accounts by unbonding release height
+	delegated     map[string]map[string]bool // head-state validators by delegator
 	mutex         sync.RWMutex
-	blockchainDB  *os.File
-	mempoolDB     *os.File
+	db            storage.Database
+	txJournal     *txJournal
 	config        ChainConfig
 }
 
 type AccountState struct {
-	Balance    uint64 `json:"balance"`
+	Balance    Amount `json:"balance"`
 	Nonce      uint64 `json:"nonce"`
-	Stake      uint64 `json:"stake,omitempty"`
+	Stake      Amount `json:"stake"`
 	LastActive int64  `json:"last_active"`
+	Validator  bool   `json:"validator,omitempty"` // registered with TxTypeRegisterValidator
+	Unbonding  []Unbonding `json:"unbonding,omitempty"` // unstaked, waiting for release
+	Commission uint16 `json:"commission,omitempty"` // basis points of delegator rewards
+	Delegations []Delegation `json:"delegations,omitempty"` // stake delegated to this validator
+	NVSScore   uint16 `json:"nvs_score,omitempty"` // posted by the NVS oracle, basis points
 }
 
 type ChainConfig struct {
@@ -30,150 +46,846 @@ type ChainConfig struct {
 	BlockTime       uint64 `json:"block_time"` // in seconds
 	Difficulty      uint64 `json:"difficulty"`
 	MaxGasLimit     uint64 `json:"max_gas_limit"`
-	MinGasPrice     uint64 `json:"min_gas_price"`
-	BlockReward     uint64 `json:"block_reward"`
+	MinGasPrice     Amount `json:"min_gas_price"`
+	BlockReward     Amount `json:"block_reward"`
+	PoVCBlock       uint64 `json:"povc_block"`
+	UnbondingPeriod uint64 `json:"unbonding_period"` // blocks from unstake to release
+	NVSOracle       string `json:"nvs_oracle,omitempty"` // account allowed to post NVS scores
+	Genesis         GenesisHeader `json:"genesis"`
 	GenesisAccounts []GenesisAccount `json:"genesis_accounts"`
 }
 
 type GenesisAccount struct {
-	Address string `json:"address"`
-	Balance uint64 `json:"balance"`
-	Stake   uint64 `json:"stake,omitempty"`
+	Address   string `json:"address"`
+	Balance   Amount `json:"balance"`
+	Stake     Amount `json:"stake"`
+	Validator bool   `json:"validator,omitempty"`
 }
 
-func NewChainManager(config ChainConfig) (*ChainManager, error) {
+// NewChainManager opens the chain stored in db, writing genesis if the
+// database is empty. A nil db keeps everything in memory.
+func NewChainManager(config ChainConfig, db storage.Database) (*ChainManager, error) {
+	if db == nil {
+		db = storage.NewMemoryDB()
+	}
+	
+	// State is keyed by checksummed address, whatever the config spelled
+	accounts := make([]GenesisAccount, len(config.GenesisAccounts))
+	for i, acc := range config.GenesisAccounts {
+		acc.Address = CanonicalAddress(acc.Address)
+		accounts[i] = acc
+	}
+	config.GenesisAccounts = accounts
+	
 	cm := &ChainManager{
 		Chain:   []*Block{},
 		State:   make(map[string]AccountState),
-		PendingTXs: []Transaction{},
+		txPool:  NewTxPool(DefaultTxPoolConfig),
+		blocks:  make(map[string]*Block),
+		totalDiff: make(map[string]uint64),
+		unbonding: make(map[uint64]map[string]bool),
+		delegated: make(map[string]map[string]bool),
+		forkChoice: LongestChain{},
+		db:      db,
 		config:  config,
 	}
 	
-	// Initialize genesis block
-	genesisBlock := createGenesisBlock(config)
-	cm.Chain = append(cm.Chain, genesisBlock)
+	headHash, err := readHeadHash(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chain head: %v", err)
+	}
+	
+	// Reopen existing datadir instead of rebuilding genesis
+	if headHash != "" {
+		if err := cm.loadChain(config, headHash); err != nil {
+			return nil, fmt.Errorf("failed to load chain: %v", err)
+		}
+		return cm, nil
+	}
+	
+	cm.stateTrie, err = trie.New("", db)
+	if err != nil {
+		return nil, err
+	}
 	
 	// Initialize genesis accounts
+	state := cm.newStateDB()
 	for _, acc := range config.GenesisAccounts {
-		cm.State[acc.Address] = AccountState{
+		state.setAccount(acc.Address, AccountState{
 			Balance:    acc.Balance,
 			Nonce:      0,
 			Stake:      acc.Stake,
 			LastActive: time.Now().Unix(),
-		}
+			Validator:  acc.Validator,
+		})
+	}
+	
+	// Persist genesis in a single batch
+	batch := db.NewBatch()
+	stateRoot, err := state.commit(batch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build genesis state: %v", err)
+	}
+	
+	// Initialize genesis block
+	genesisBlock := createGenesisBlock(config, stateRoot)
+	supply := genesisSupply(state.dirty)
+	
+	if err := writeChainConfig(batch, config); err != nil {
+		return nil, err
+	}
+	if err := writeBlock(batch, genesisBlock, genesisBlock.Header.Difficulty); err != nil {
+		return nil, err
+	}
+	writeCanonicalHead(batch, genesisBlock)
+	if err := writeSupply(batch, genesisBlock.Hash(), supply); err != nil {
+		return nil, err
+	}
+	if err := writeValidatorSet(batch, genesisBlock.Hash(), activeValidators(state.base, state.dirty)); err != nil {
+		return nil, err
+	}
+	if err := batch.Write(); err != nil {
+		return nil, fmt.Errorf("failed to write genesis: %v", err)
 	}
 	
+	cm.Chain = append(cm.Chain, genesisBlock)
+	cm.blocks[genesisBlock.Hash()] = genesisBlock
+	cm.totalDiff[genesisBlock.Hash()] = genesisBlock.Header.Difficulty
+	cm.commitState(state, 0)
+	cm.supply = supply
+	cm.finalized = genesisBlock
+	
 	return cm, nil
 }
 
-func createGenesisBlock(config ChainConfig) *Block {
+func (cm *ChainManager) loadChain(config ChainConfig, headHash string) error {
+	stored, err := readChainConfig(cm.db)
+	if err != nil {
+		return fmt.Errorf("missing chain config: %v", err)
+	}
+	if stored.ChainID != config.ChainID {
+		return fmt.Errorf("datadir belongs to chain %d, not %d", stored.ChainID, config.ChainID)
+	}
+	cm.config = *stored
+	
+	// Refuse a datadir initialised from a different genesis
+	expected, err := GenesisBlock(config)
+	if err != nil {
+		return fmt.Errorf("failed to build genesis: %v", err)
+	}
+	genesisHash, err := readCanonicalHash(cm.db, 0)
+	if err != nil {
+		return fmt.Errorf("missing genesis block: %v", err)
+	}
+	if genesisHash != expected.Hash() {
+		return fmt.Errorf("datadir genesis %s does not match configured genesis %s", genesisHash, expected.Hash())
+	}
+	
+	cm.blocks, cm.totalDiff, err = readBlockTree(cm.db)
+	if err != nil {
+		return err
+	}
+	
+	head, exists := cm.blocks[headHash]
+	if !exists {
+		return fmt.Errorf("missing head block %s", headHash)
+	}
+	
+	for height := uint64(0); height <= head.Header.Height; height++ {
+		hash, err := readCanonicalHash(cm.db, height)
+		if err != nil {
+			return fmt.Errorf("missing canonical hash at height %d: %v", height, err)
+		}
+		block, exists := cm.blocks[hash]
+		if !exists {
+			return fmt.Errorf("missing block %d", height)
+		}
+		cm.Chain = append(cm.Chain, block)
+	}
+	
+	// Genesis is final until a justification says otherwise
+	cm.finalized = cm.Chain[0]
+	finalizedHash, err := readFinalizedHash(cm.db)
+	if err != nil {
+		return fmt.Errorf("failed to read finalized block: %v", err)
+	}
+	if finalizedHash != "" {
+		block, exists := cm.blocks[finalizedHash]
+		if !exists || !cm.isCanonical(block) {
+			return fmt.Errorf("finalized block %s is not canonical", finalizedHash)
+		}
+		cm.finalized = block
+	}
+	
+	state, err := readAccounts(cm.db)
+	if err != nil {
+		return err
+	}
+	cm.State = state
+	for address, account := range state {
+		cm.indexUnbonding(address, account, head.Header.Height+1)
+		cm.indexDelegations(address, AccountState{}, account)
+	}
+	
+	cm.stateTrie, err = trie.New(head.Header.StateRoot, cm.db)
+	if err != nil {
+		return fmt.Errorf("failed to open state trie: %v", err)
+	}
+	cm.loadSupply(head)
+	
+	return nil
+}
+
+// Close the underlying database
+func (cm *ChainManager) Close() error {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+	
+	// Leave a compact journal behind for the next start
+	if cm.txJournal != nil {
+		if err := cm.txJournal.rotate(cm.txPool.allTxs()); err != nil {
+			fmt.Printf("⚠️  Failed to write transaction journal: %v\n", err)
+		}
+		cm.txJournal.close()
+	}
+	return cm.db.Close()
+}
+
+// OpenTxJournal reloads the mempool saved at path, re-validating every
+// transaction against the head state, and journals new ones from now on
+func (cm *ChainManager) OpenTxJournal(path string) error {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+	
+	journal := newTxJournal(path)
+	loaded, dropped, err := journal.load(cm.addTransaction)
+	if err != nil {
+		return fmt.Errorf("failed to load transaction journal: %v", err)
+	}
+	if err := journal.rotate(cm.txPool.allTxs()); err != nil {
+		return fmt.Errorf("failed to rotate transaction journal: %v", err)
+	}
+	cm.txJournal = journal
+	
+	if loaded > 0 || dropped > 0 {
+		fmt.Printf("📥 Restored %d transactions from journal, dropped %d\n", loaded, dropped)
+	}
+	return nil
+}
+
+// Re-sort the pool against a new head and compact the journal when needed;
+// the caller holds cm.mutex
+func (cm *ChainManager) resetTxPool() {
+	cm.txPool.reset(cm.State)
+	if cm.txJournal != nil && cm.txJournal.needsRotate(len(cm.txPool.all)) {
+		if err := cm.txJournal.rotate(cm.txPool.allTxs()); err != nil {
+			fmt.Printf("⚠️  Failed to rotate transaction journal: %v\n", err)
+		}
+	}
+}
+
+// Block 0 depends only on config, so every node derives the same genesis hash
+func createGenesisBlock(config ChainConfig, stateRoot string) *Block {
 	// Create genesis transactions from genesis accounts
 	var genesisTXs []Transaction
 	for _, acc := range config.GenesisAccounts {
 		tx := Transaction{
-			Hash:      "genesis_" + acc.Address,
 			Nonce:     0,
 			From:      "0x0000000000000000000000000000000000000000",
 			To:        acc.Address,
 			Value:     acc.Balance,
-			GasPrice:  0,
+			GasPrice:  Amount{},
 			GasLimit:  0,
-			Timestamp: time.Now().Unix(),
+			Timestamp: config.Genesis.Timestamp,
 		}
+		tx.Hash = tx.CalculateHash()
 		genesisTXs = append(genesisTXs, tx)
 	}
 	
-	genesisBlock := NewBlock(0, "0", genesisTXs, "genesis")
-	genesisBlock.Header.Timestamp = 1701388800 // Fixed timestamp
+	validator := config.Genesis.Coinbase
+	if validator == "" {
+		validator = "genesis"
+	}
+	
+	genesisBlock := NewBlock(0, "0", genesisTXs, validator)
+	genesisBlock.Header.Timestamp = config.Genesis.Timestamp
+	genesisBlock.Header.Nonce = config.Genesis.Nonce
 	genesisBlock.Header.Difficulty = 1
+	if config.Genesis.Difficulty > 0 {
+		genesisBlock.Header.Difficulty = config.Genesis.Difficulty
+	}
+	if config.Genesis.GasLimit > 0 {
+		genesisBlock.Header.GasLimit = config.Genesis.GasLimit
+	}
+	genesisBlock.Header.ExtraData = config.Genesis.ExtraData
+	genesisBlock.Header.StateRoot = stateRoot
+	genesisBlock.Header.ReceiptsRoot = DeriveReceiptsRoot(nil)
+	genesisBlock.Header.BaseFee = config.MinGasPrice
 	
 	return genesisBlock
 }
 
-// Add new block to chain
+// SetForkChoice replaces the rule used to pick between competing branches
+func (cm *ChainManager) SetForkChoice(rule ForkChoice) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+	cm.forkChoice = rule
+}
+
+// Add new block to the block tree. Blocks extending the head are applied
+// directly; blocks on a side branch are kept and trigger a reorg when the
+// fork-choice rule prefers their branch.
 func (cm *ChainManager) AddBlock(block *Block) error {
 	cm.mutex.Lock()
 	defer cm.mutex.Unlock()
 	
-	// Validate block
-	if !block.Validate(cm.GetLatestBlock()) {
-		return fmt.Errorf("invalid block")
+	hash := block.Hash()
+	known, isKnown := cm.blocks[hash]
+	if isKnown && cm.isCanonical(known) {
+		return fmt.Errorf("block %s already known", hash)
 	}
 	
-	// Apply transactions
-	for _, tx := range block.Transactions {
-		if err := cm.applyTransaction(tx); err != nil {
-			return fmt.Errorf("failed to apply transaction: %v", err)
+	parent, exists := cm.blocks[block.Header.PrevHash]
+	if !exists {
+		return fmt.Errorf("unknown parent block %s", block.Header.PrevHash)
+	}
+	
+	// Common case: extend the canonical head
+	if parent == cm.latestBlock() {
+		if err := cm.applyBlock(block); err != nil {
+			return err
 		}
+		cm.resetTxPool()
+		return nil
 	}
 	
-	// Update validator stake (PoVC reward)
-	cm.updateValidatorReward(block.Header.Validator, block.Header.Reward)
+	// Side branch: keep the block; it is executed only if its branch wins
+	ancestor := cm.commonAncestor(parent)
+	if ancestor.Header.Height < cm.finalized.Header.Height {
+		return fmt.Errorf("block %d forks off below finalized block %d", block.Header.Height, cm.finalized.Header.Height)
+	}
+	if !isKnown {
+		// Check what can be checked before execution. The proposer needs
+		// the validator set of the parent state, recorded once the parent
+		// was executed; deeper in a branch that never ran it is checked when
+		// a reorg applies the branch. Either way every stored side block
+		// descends from a block its scheduled proposer signed.
+		executed, err := cm.db.Has(validatorSetKey(parent.Hash()))
+		if err != nil {
+			return fmt.Errorf("failed to look up validator set: %v", err)
+		}
+		proposer := ""
+		if executed {
+			if proposer, err = cm.scheduledProposer(parent, block.Header.Timestamp); err != nil {
+				return err
+			}
+		}
+		if err := block.verifyHeader(parent, proposer); err != nil {
+			return fmt.Errorf("invalid block: %v", err)
+		}
+		td, err := cm.totalDifficulty(block)
+		if err != nil {
+			return err
+		}
+		
+		batch := cm.db.NewBatch()
+		if err := writeBlock(batch, block, td); err != nil {
+			return err
+		}
+		if err := batch.Write(); err != nil {
+			return fmt.Errorf("failed to persist side block: %v", err)
+		}
+		cm.blocks[hash] = block
+		cm.totalDiff[hash] = td
+	}
+	
+	if !cm.forkChoice.ShouldReorg(cm.tip(cm.latestBlock()), cm.tip(block), cm.tip(ancestor)) {
+		return nil
+	}
+	return cm.reorg(block, ancestor)
+}
+
+// Total difficulty of the branch ending at block, whose parent is known
+func (cm *ChainManager) totalDifficulty(block *Block) (uint64, error) {
+	td, overflow := math.SafeAdd(cm.totalDiff[block.Header.PrevHash], block.Header.Difficulty)
+	if overflow {
+		return 0, fmt.Errorf("total difficulty overflows at block %d", block.Header.Height)
+	}
+	return td, nil
+}
+
+func (cm *ChainManager) tip(block *Block) ChainTip {
+	hash := block.Hash()
+	return ChainTip{
+		Hash:            hash,
+		Height:          block.Header.Height,
+		TotalDifficulty: cm.totalDiff[hash],
+	}
+}
+
+// Whether block is part of the canonical chain
+func (cm *ChainManager) isCanonical(block *Block) bool {
+	height := block.Header.Height
+	return height < uint64(len(cm.Chain)) && cm.Chain[height].Hash() == block.Hash()
+}
+
+// Last canonical block on the path from block back to genesis
+func (cm *ChainManager) commonAncestor(block *Block) *Block {
+	for !cm.isCanonical(block) {
+		block = cm.blocks[block.Header.PrevHash]
+	}
+	return block
+}
+
+// reorg unwinds the canonical chain to ancestor and applies the branch
+// ending at newHead. If any branch block fails, the old chain is restored.
+func (cm *ChainManager) reorg(newHead *Block, ancestor *Block) error {
+	var branch []*Block
+	for b := newHead; b != ancestor; b = cm.blocks[b.Header.PrevHash] {
+		branch = append([]*Block{b}, branch...)
+	}
+	
+	reverted, err := cm.revertTo(ancestor)
+	if err != nil {
+		return fmt.Errorf("reorg failed to revert block: %v", err)
+	}
+	
+	// Oldest first, for re-applying
+	removed := make([]*Block, len(reverted))
+	for i, block := range reverted {
+		removed[len(reverted)-1-i] = block
+	}
+	
+	for i, block := range branch {
+		if err := cm.applyBlock(block); err != nil {
+			applyErr := fmt.Errorf("reorg to %s failed at height %d: %v", newHead.Hash(), block.Header.Height, err)
+			
+			// Drop the invalid block and everything built on it
+			cm.discardBranch(branch[i:])
+			
+			// Back to the old chain, which was valid
+			if _, err := cm.revertTo(ancestor); err != nil {
+				return fmt.Errorf("%v; restoring old chain: %v", applyErr, err)
+			}
+			for _, old := range removed {
+				if err := cm.applyBlock(old); err != nil {
+					return fmt.Errorf("%v; restoring old chain: %v", applyErr, err)
+				}
+			}
+			return applyErr
+		}
+	}
+	
+	// Orphaned transactions go back to the mempool
+	included := make(map[string]bool)
+	for _, block := range branch {
+		for _, tx := range block.Transactions {
+			included[tx.Hash] = true
+		}
+	}
+	for _, block := range removed {
+		for _, tx := range block.Transactions {
+			if !included[tx.Hash] && cm.txPool.add(tx, cm.State) == nil && cm.txJournal != nil {
+				cm.txJournal.insert(&tx)
+			}
+		}
+	}
+	cm.resetTxPool()
+	
+	fmt.Printf("🔀 Reorg: %d blocks replaced by %d, new head #%d\n", len(removed), len(branch), newHead.Header.Height)
+	return nil
+}
+
+// Revert canonical blocks until ancestor is the head; returns them head first
+func (cm *ChainManager) revertTo(ancestor *Block) ([]*Block, error) {
+	var reverted []*Block
+	for cm.latestBlock() != ancestor {
+		block, err := cm.revertHead()
+		if err != nil {
+			return reverted, err
+		}
+		reverted = append(reverted, block)
+	}
+	return reverted, nil
+}
+
+// Forget side blocks that failed to execute, together with their descendants
+func (cm *ChainManager) discardBranch(bad []*Block) {
+	invalid := make(map[string]bool)
+	for _, block := range bad {
+		invalid[block.Hash()] = true
+	}
+	
+	// Descendants of an invalid block are invalid too
+	for changed := true; changed; {
+		changed = false
+		for hash, block := range cm.blocks {
+			if !invalid[hash] && invalid[block.Header.PrevHash] {
+				invalid[hash] = true
+				changed = true
+			}
+		}
+	}
+	
+	batch := cm.db.NewBatch()
+	for hash := range invalid {
+		batch.Delete(blockKey(hash))
+		batch.Delete(tdKey(hash))
+		delete(cm.blocks, hash)
+		delete(cm.totalDiff, hash)
+	}
+	if err := batch.Write(); err != nil {
+		fmt.Printf("⚠️  Failed to delete invalid blocks: %v\n", err)
+	}
+}
+
+// applyBlock executes block on top of the head and makes it the new head;
+// the caller holds cm.mutex
+func (cm *ChainManager) applyBlock(block *Block) error {
+	if err := cm.validateBlockReward(block); err != nil {
+		return fmt.Errorf("invalid block: %v", err)
+	}
+	
+	// Execute on a staged copy of the head state
+	state := cm.newStateDB()
+	receipts, err := cm.executeBlock(state, block)
+	if err != nil {
+		return err
+	}
+	
+	stateRoot, err := state.intermediateRoot()
+	if err != nil {
+		return fmt.Errorf("failed to compute state root: %v", err)
+	}
+	
+	// Validate block
+	proposer, err := cm.scheduledProposer(cm.latestBlock(), block.Header.Timestamp)
+	if err != nil {
+		return err
+	}
+	if err := block.verify(cm.latestBlock(), proposer, stateRoot, DeriveReceiptsRoot(receipts)); err != nil {
+		return fmt.Errorf("invalid block: %v", err)
+	}
+	if err := cm.validateBlockGas(block, cm.latestBlock(), blockGasUsed(receipts)); err != nil {
+		return err
+	}
+	supply, err := cm.nextSupply(state, block)
+	if err != nil {
+		return err
+	}
+	return cm.insertBlock(block, state, receipts, supply)
+}
+
+// insertBlock makes an executed and validated block the new head
+func (cm *ChainManager) insertBlock(block *Block, state *stateDB, receipts []*Receipt, supply *Supply) error {
+	// Persist block, head pointer, receipts and state atomically
+	hash := block.Hash()
+	for i, receipt := range receipts {
+		receipt.BlockHash = hash
+		receipt.BlockHeight = block.Header.Height
+		receipt.TxIndex = uint64(i)
+	}
+	td, err := cm.totalDifficulty(block)
+	if err != nil {
+		return err
+	}
+	batch := cm.db.NewBatch()
+	if err := writeBlock(batch, block, td); err != nil {
+		return err
+	}
+	writeCanonicalHead(batch, block)
+	if err := writeReceipts(batch, hash, receipts); err != nil {
+		return err
+	}
+	writeTxIndexes(batch, block)
+	if err := writeSupply(batch, hash, supply); err != nil {
+		return err
+	}
+	if err := writeValidatorSet(batch, hash, activeValidators(state.base, state.dirty)); err != nil {
+		return err
+	}
+	if err := writeUndo(batch, hash, state.undoRecord()); err != nil {
+		return err
+	}
+	if _, err := state.commit(batch); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return fmt.Errorf("failed to persist block: %v", err)
+	}
 	
 	// Add block to chain
+	cm.commitState(state, block.Header.Height)
 	cm.Chain = append(cm.Chain, block)
+	cm.blocks[hash] = block
+	cm.totalDiff[hash] = td
+	cm.supply = supply
+	
+	return nil
+}
+
+// RevertBlocks unwinds the last n blocks, restoring the state each one
+// started from. Reverted blocks are returned head first.
+func (cm *ChainManager) RevertBlocks(n int) ([]*Block, error) {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+	
+	var reverted []*Block
+	defer cm.resetTxPool()
+	for i := 0; i < n; i++ {
+		block, err := cm.revertHead()
+		if err != nil {
+			return reverted, err
+		}
+		reverted = append(reverted, block)
+	}
+	return reverted, nil
+}
+
+// revertHead removes the head block in one atomic batch; the caller holds cm.mutex
+func (cm *ChainManager) revertHead() (*Block, error) {
+	if len(cm.Chain) <= 1 {
+		return nil, fmt.Errorf("cannot revert genesis block")
+	}
+	head := cm.Chain[len(cm.Chain)-1]
+	parent := cm.Chain[len(cm.Chain)-2]
+	if head == cm.finalized {
+		return nil, fmt.Errorf("cannot revert finalized block %d", head.Header.Height)
+	}
+	
+	undo, err := readUndo(cm.db, head.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("missing undo record for block %d: %v", head.Header.Height, err)
+	}
+	parentTrie, err := trie.New(parent.Header.StateRoot, cm.db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open parent state: %v", err)
+	}
+	
+	batch := cm.db.NewBatch()
+	writeCanonicalRevert(batch, head)
+	deleteTxIndexes(batch, head)
+	for _, account := range undo {
+		if account.Existed {
+			if err := writeAccount(batch, account.Address, account.State); err != nil {
+				return nil, err
+			}
+		} else {
+			deleteAccount(batch, account.Address)
+		}
+	}
+	if err := batch.Write(); err != nil {
+		return nil, fmt.Errorf("failed to persist revert: %v", err)
+	}
+	
+	for _, account := range undo {
+		cm.indexDelegations(account.Address, cm.State[account.Address], account.State)
+		if account.Existed {
+			cm.State[account.Address] = account.State
+			cm.indexUnbonding(account.Address, account.State, head.Header.Height)
+		} else {
+			delete(cm.State, account.Address)
+		}
+	}
+	cm.stateTrie = parentTrie
+	cm.Chain = cm.Chain[:len(cm.Chain)-1]
+	cm.loadSupply(parent)
+	
+	return head, nil
+}
+
+// SealStateRoot executes block on top of the head state and records the
+// resulting base fee, state root, receipts root and gas used in its header.
+// Producers call it before AddBlock.
+func (cm *ChainManager) SealStateRoot(block *Block) error {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
 	
-	// Remove processed transactions from mempool
-	cm.removeFromMempool(block.Transactions)
+	block.Header.BaseFee = cm.nextBaseFee()
 	
+	state := cm.newStateDB()
+	receipts, err := cm.executeBlock(state, block)
+	if err != nil {
+		return err
+	}
+	
+	stateRoot, err := state.intermediateRoot()
+	if err != nil {
+		return err
+	}
+	block.Header.StateRoot = stateRoot
+	block.Header.ReceiptsRoot = DeriveReceiptsRoot(receipts)
+	block.Header.GasUsed = blockGasUsed(receipts)
 	return nil
 }
 
-func (cm *ChainManager) applyTransaction(tx Transaction) error {
+// executeBlock applies transactions, nonces and the validator reward as one
+// unit: on any failure state is rolled back to where it was before the block.
+func (cm *ChainManager) executeBlock(state *stateDB, block *Block) ([]*Receipt, error) {
+	snapshot := state.snapshot()
+	
+	// Matured unstakes are paid out before any transaction can spend them
+	if err := cm.releaseUnbonded(state, block.Header.Height); err != nil {
+		state.revertToSnapshot(snapshot)
+		return nil, err
+	}
+	
+	// Apply transactions
+	var receipts []*Receipt
+	var cumulativeGas uint64
+	for i, tx := range block.Transactions {
+		if cumulativeGas+tx.GasLimit > block.Header.GasLimit {
+			state.revertToSnapshot(snapshot)
+			return nil, fmt.Errorf("transaction %d (%s) exceeds block gas limit %d", i, tx.Hash, block.Header.GasLimit)
+		}
+		receipt, err := cm.applyTransaction(state, &block.Header, tx, cumulativeGas)
+		if err != nil {
+			state.revertToSnapshot(snapshot)
+			return nil, fmt.Errorf("failed to apply transaction %d (%s): %v", i, tx.Hash, err)
+		}
+		cumulativeGas = receipt.CumulativeGasUsed
+		receipts = append(receipts, receipt)
+	}
+	
+	// Update validator stake (PoVC reward)
+	if err := cm.updateValidatorReward(state, block.Header.Validator, block.Header.Reward); err != nil {
+		state.revertToSnapshot(snapshot)
+		return nil, err
+	}
+	
+	return receipts, nil
+}
+
+// Fold a committed stateDB into the head state of block height, whose
+// unbonding entries have been released
+func (cm *ChainManager) commitState(state *stateDB, height uint64) {
+	for address, account := range state.dirty {
+		cm.indexDelegations(address, cm.State[address], account)
+		cm.State[address] = account
+		cm.indexUnbonding(address, account, height+1)
+	}
+	delete(cm.unbonding, height)
+	cm.stateTrie = state.trie
+}
+
+// applyTransaction executes tx in the block with the given header and
+// returns its receipt. An error means tx cannot be included at all (bad
+// nonce, gas unaffordable); a transaction that fails after gas is bought is
+// included with a failed receipt.
+func (cm *ChainManager) applyTransaction(state *stateDB, header *BlockHeader, tx Transaction, cumulativeGas uint64) (*Receipt, error) {
 	// Check sender balance
-	senderState, exists := cm.State[tx.From]
+	senderState, exists := state.getAccount(tx.From)
 	if !exists {
-		senderState = AccountState{Nonce: 0, Balance: 0}
+		senderState = AccountState{Nonce: 0}
 	}
 	
 	// Check nonce
 	if tx.Nonce != senderState.Nonce {
-		return fmt.Errorf("invalid nonce: expected %d, got %d", senderState.Nonce, tx.Nonce)
+		return nil, fmt.Errorf("invalid nonce: expected %d, got %d", senderState.Nonce, tx.Nonce)
 	}
 	
-	// Calculate total cost
-	totalCost := tx.Value + (tx.GasPrice * tx.GasLimit)
-	if senderState.Balance < totalCost {
-		return fmt.Errorf("insufficient balance")
+	if err := cm.checkChainID(&tx); err != nil {
+		return nil, err
+	}
+	if err := cm.checkGas(&tx); err != nil {
+		return nil, err
 	}
 	
-	// Update sender
-	senderState.Balance -= totalCost
+	if tx.FeeCap().Cmp(header.BaseFee) < 0 {
+		return nil, fmt.Errorf("fee cap %s below base fee %s", tx.FeeCap(), header.BaseFee)
+	}
+	
+	// Buy the full gas limit up front at the fee cap
+	gasCost, err := tx.FeeCap().MulUint64(tx.GasLimit)
+	if err != nil {
+		return nil, fmt.Errorf("gas cost: %v", err)
+	}
+	if senderState.Balance.Cmp(gasCost) < 0 {
+		return nil, fmt.Errorf("insufficient balance for gas")
+	}
+	
+	// Refund unused gas; the fee and nonce stick even if the transfer fails
+	gasUsed := IntrinsicGas(tx.Data)
+	price := tx.EffectiveGasPrice(header.BaseFee)
+	fee, _ := price.MulUint64(gasUsed)
+	senderState.Balance, _ = senderState.Balance.Sub(fee)
 	senderState.Nonce++
 	senderState.LastActive = time.Now().Unix()
-	cm.State[tx.From] = senderState
+	state.setAccount(tx.From, senderState)
+	
+	// The base fee part is burned, the validator gets the tip
+	tipPerGas, _ := price.Sub(header.BaseFee)
+	tip, _ := tipPerGas.MulUint64(gasUsed)
+	if !tip.IsZero() {
+		validator, _ := state.getAccount(header.Validator)
+		validator.Balance, err = validator.Balance.Add(tip)
+		if err != nil {
+			return nil, fmt.Errorf("validator tip: %v", err)
+		}
+		state.setAccount(header.Validator, validator)
+	}
+	
+	receipt := &Receipt{
+		TxHash:            tx.Hash,
+		Status:            ReceiptStatusSuccessful,
+		GasUsed:           gasUsed,
+		CumulativeGasUsed: cumulativeGas + gasUsed,
+		Fee:               fee,
+		Logs:              []Log{},
+	}
+	
+	snapshot := state.snapshot()
+	logs, err := cm.executeTx(state, header, &tx)
+	if err != nil {
+		state.revertToSnapshot(snapshot)
+		receipt.Status = ReceiptStatusFailed
+		receipt.Error = err.Error()
+		return receipt, nil
+	}
+	receipt.Logs = append(receipt.Logs, logs...)
+	
+	return receipt, nil
+}
+
+// Move tx.Value from sender to receiver
+func (cm *ChainManager) transfer(state *stateDB, tx Transaction) error {
+	// Update sender
+	senderState, _ := state.getAccount(tx.From)
+	balance, err := senderState.Balance.Sub(tx.Value)
+	if err != nil {
+		return fmt.Errorf("insufficient balance")
+	}
+	senderState.Balance = balance
+	state.setAccount(tx.From, senderState)
 	
 	// Update receiver
-	receiverState := cm.State[tx.To]
-	receiverState.Balance += tx.Value
+	receiverState, _ := state.getAccount(tx.To)
+	receiverState.Balance, err = receiverState.Balance.Add(tx.Value)
+	if err != nil {
+		return fmt.Errorf("receiver balance: %v", err)
+	}
 	receiverState.LastActive = time.Now().Unix()
-	cm.State[tx.To] = receiverState
+	state.setAccount(tx.To, receiverState)
 	
 	return nil
 }
 
-func (cm *ChainManager) updateValidatorReward(validator string, reward uint64) {
-	state := cm.State[validator]
-	state.Balance += reward
-	state.LastActive = time.Now().Unix()
-	cm.State[validator] = state
-}
-
-func (cm *ChainManager) removeFromMempool(txs []Transaction) {
-	newMempool := []Transaction{}
-	for _, pending := range cm.PendingTXs {
-		found := false
-		for _, processed := range txs {
-			if pending.Hash == processed.Hash {
-				found = true
-				break
-			}
-		}
-		if !found {
-			newMempool = append(newMempool, pending)
-		}
+// updateValidatorReward credits the block reward, split between the
+// validator and its delegators
+func (cm *ChainManager) updateValidatorReward(state *stateDB, validator string, reward Amount) error {
+	account, _ := state.getAccount(validator)
+	reward, err := splitReward(&account, reward)
+	if err != nil {
+		return err
+	}
+	balance, err := account.Balance.Add(reward)
+	if err != nil {
+		return fmt.Errorf("validator reward: %v", err)
 	}
-	cm.PendingTXs = newMempool
+	account.Balance = balance
+	account.LastActive = time.Now().Unix()
+	state.setAccount(validator, account)
+	return nil
 }
 
 // Add transaction to mempool
@@ -181,19 +893,40 @@ func (cm *ChainManager) AddTransaction(tx Transaction) error {
 	cm.mutex.Lock()
 	defer cm.mutex.Unlock()
 	
-	// Basic validation
-	if !tx.Validate() {
-		return fmt.Errorf("invalid transaction")
+	if err := cm.addTransaction(tx); err != nil {
+		return err
 	}
-	
-	// Check if already in mempool
-	for _, pending := range cm.PendingTXs {
-		if pending.Hash == tx.Hash {
-			return fmt.Errorf("transaction already in mempool")
+	if cm.txJournal != nil {
+		if err := cm.txJournal.insert(&tx); err != nil {
+			fmt.Printf("⚠️  Failed to journal transaction %s: %v\n", tx.Hash, err)
 		}
 	}
+	return nil
+}
+
+// addTransaction validates tx and puts it in the pool; the caller holds cm.mutex
+func (cm *ChainManager) addTransaction(tx Transaction) error {
+	// Basic validation
+	if err := tx.verify(); err != nil {
+		return fmt.Errorf("invalid transaction: %v", err)
+	}
+	if err := cm.checkChainID(&tx); err != nil {
+		return err
+	}
+	if err := cm.checkGas(&tx); err != nil {
+		return err
+	}
 	
-	cm.PendingTXs = append(cm.PendingTXs, tx)
+	// Nonce, balance, replacement and size limits are checked against the head state
+	return cm.txPool.add(tx, cm.State)
+}
+
+// A transaction is signed for one chain, so it cannot be replayed on
+// another network sharing its accounts
+func (cm *ChainManager) checkChainID(tx *Transaction) error {
+	if tx.ChainID != cm.config.ChainID {
+		return fmt.Errorf("transaction for chain %d, this is chain %d", tx.ChainID, cm.config.ChainID)
+	}
 	return nil
 }
 
@@ -201,13 +934,24 @@ func (cm *ChainManager) AddTransaction(tx Transaction) error {
 func (cm *ChainManager) GetLatestBlock() *Block {
 	cm.mutex.RLock()
 	defer cm.mutex.RUnlock()
-	
+	return cm.latestBlock()
+}
+
+// latestBlock assumes the caller holds cm.mutex
+func (cm *ChainManager) latestBlock() *Block {
 	if len(cm.Chain) == 0 {
 		return nil
 	}
 	return cm.Chain[len(cm.Chain)-1]
 }
 
+// Hash of block 0, identifying the genesis this node runs
+func (cm *ChainManager) GenesisHash() string {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+	return cm.Chain[0].Hash()
+}
+
 // Get chain height
 func (cm *ChainManager) GetHeight() uint64 {
 	cm.mutex.RLock()
@@ -215,70 +959,155 @@ func (cm *ChainManager) GetHeight() uint64 {
 	return uint64(len(cm.Chain))
 }
 
+// GetReceipt returns the receipt of a transaction on the canonical chain
+func (cm *ChainManager) GetReceipt(txHash string) (*Receipt, error) {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+	
+	lookup, err := cm.txLookup(txHash)
+	if err != nil {
+		return nil, err
+	}
+	blockHash := lookup.BlockHash
+	receipts, err := readReceipts(cm.db, blockHash)
+	if err != nil {
+		return nil, fmt.Errorf("missing receipts for block %s: %v", blockHash, err)
+	}
+	for _, receipt := range receipts {
+		if receipt.TxHash == txHash {
+			return receipt, nil
+		}
+	}
+	return nil, fmt.Errorf("transaction %s not found in block %s", txHash, blockHash)
+}
+
+// TxLookup locates a transaction on the canonical chain
+type TxLookup struct {
+	TxHash      string `json:"tx_hash"`
+	BlockHash   string `json:"block_hash"`
+	BlockHeight uint64 `json:"block_height"`
+	Index       uint64 `json:"index"`
+}
+
+// GetBlockByHash returns a known block, canonical or on a side branch
+func (cm *ChainManager) GetBlockByHash(hash string) (*Block, error) {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+	
+	block, exists := cm.blocks[hash]
+	if !exists {
+		return nil, fmt.Errorf("block %s not found", hash)
+	}
+	return block, nil
+}
+
+// GetBlockByHeight returns the canonical block at height
+func (cm *ChainManager) GetBlockByHeight(height uint64) (*Block, error) {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+	
+	if height >= uint64(len(cm.Chain)) {
+		return nil, fmt.Errorf("block %d not found", height)
+	}
+	return cm.Chain[height], nil
+}
+
+// GetTransaction returns a canonical transaction and where it was included
+func (cm *ChainManager) GetTransaction(txHash string) (*Transaction, *TxLookup, error) {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+	
+	lookup, err := cm.txLookup(txHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	block, exists := cm.blocks[lookup.BlockHash]
+	if !exists || lookup.Index >= uint64(len(block.Transactions)) {
+		return nil, nil, fmt.Errorf("transaction %s points at missing block %s", txHash, lookup.BlockHash)
+	}
+	tx := block.Transactions[lookup.Index]
+	return &tx, lookup, nil
+}
+
+// GetAddressTxs pages through the canonical transactions sent or received
+// by address, newest first. Pass "" as cursor for the first page and the
+// returned cursor for the next; it is "" after the last page.
+func (cm *ChainManager) GetAddressTxs(address, cursor string, limit int) ([]TxLookup, string, error) {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+	
+	if limit <= 0 {
+		return nil, "", fmt.Errorf("invalid limit %d", limit)
+	}
+	return readAddressTxs(cm.db, CanonicalAddress(address), cursor, limit)
+}
+
+// txLookup assumes the caller holds cm.mutex
+func (cm *ChainManager) txLookup(txHash string) (*TxLookup, error) {
+	lookup, err := readTxLookup(cm.db, txHash)
+	if err == storage.ErrNotFound {
+		return nil, fmt.Errorf("transaction %s not found", txHash)
+	}
+	return lookup, err
+}
+
 // Get account balance
-func (cm *ChainManager) GetBalance(address string) uint64 {
+func (cm *ChainManager) GetBalance(address string) Amount {
 	cm.mutex.RLock()
 	defer cm.mutex.RUnlock()
 	
-	state, exists := cm.State[address]
+	state, exists := cm.State[CanonicalAddress(address)]
 	if !exists {
-		return 0
+		return Amount{}
 	}
 	return state.Balance
 }
 
-// Get pending transactions
+// GetPendingTXs returns a copy of the executable transactions in block order
 func (cm *ChainManager) GetPendingTXs() []Transaction {
 	cm.mutex.RLock()
 	defer cm.mutex.RUnlock()
-	return cm.PendingTXs
+	return cm.txPool.pendingTxs()
+}
+
+// TxPoolStatus returns how many transactions are executable and how many
+// wait behind a nonce gap
+func (cm *ChainManager) TxPoolStatus() (pending, queued int) {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+	return cm.txPool.stats()
 }
 
-// Create new transaction
-func (cm *ChainManager) CreateTransaction(from, to string, value uint64, data []byte) (*Transaction, error) {
+// Create new unsigned transaction; the sender signs it with Transaction.Sign
+func (cm *ChainManager) CreateTransaction(from, to string, value Amount, data []byte) (*Transaction, error) {
 	cm.mutex.RLock()
 	defer cm.mutex.RUnlock()
 	
+	from = CanonicalAddress(from)
+	if to != "" {
+		to = CanonicalAddress(to)
+	}
 	state, exists := cm.State[from]
 	if !exists {
-		state = AccountState{Nonce: 0, Balance: 0}
+		state = AccountState{Nonce: 0}
 	}
 	
+	maxFee, priorityFee := cm.suggestFees()
 	tx := Transaction{
+		ChainID:   cm.config.ChainID,
 		Nonce:     state.Nonce,
 		From:      from,
 		To:        to,
 		Value:     value,
-		GasPrice:  cm.config.MinGasPrice,
-		GasLimit:  21000, // Basic transfer
+		GasLimit:  IntrinsicGas(data),
 		Data:      data,
 		Timestamp: time.Now().Unix(),
+		
+		MaxFeePerGas:         maxFee,
+		MaxPriorityFeePerGas: priorityFee,
 	}
 	
 	tx.Hash = tx.CalculateHash()
 	
 	return &tx, nil
 }
-
-// Export chain to JSON
-func (cm *ChainManager) ExportChain() (string, error) {
-	cm.mutex.RLock()
-	defer cm.mutex.RUnlock()
-	
-	data := struct {
-		Chain   []*Block               `json:"chain"`
-		State   map[string]AccountState `json:"state"`
-		Pending []Transaction          `json:"pending_txs"`
-	}{
-		Chain:   cm.Chain,
-		State:   cm.State,
-		Pending: cm.PendingTXs,
-	}
-	
-	bytes, err := json.MarshalIndent(data, "", "  ")
-	if err != nil {
-		return "", err
-	}
-	
-	return string(bytes), nil
-}
\ No newline at end of file
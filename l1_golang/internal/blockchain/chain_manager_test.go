@@ -0,0 +1,279 @@
+package blockchain
+
+import (
+	"crypto/ecdsa"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+
+	"nusa-chain/internal/storage"
+)
+
+// testChain is a chain whose genesis funds a few keys. Blocks are signed
+// by producer while no validator is registered, and by the scheduled
+// proposer once one is.
+type testChain struct {
+	t        *testing.T
+	config   ChainConfig
+	cm       *ChainManager
+	keys     []*ecdsa.PrivateKey
+	addrs    []string
+	producer *ecdsa.PrivateKey
+}
+
+func newTestChain(t *testing.T, accounts int) *testChain {
+	c := &testChain{t: t, config: ChainConfig{ChainID: 1, BlockReward: NUSA(2)}}
+	for i := 0; i < accounts; i++ {
+		key := newTestKey(t)
+		c.keys = append(c.keys, key)
+		c.addrs = append(c.addrs, keyAddress(key))
+		c.config.GenesisAccounts = append(c.config.GenesisAccounts, GenesisAccount{Address: keyAddress(key), Balance: NUSA(100)})
+	}
+	return c.fork(nil)
+}
+
+// fork starts another node of the same chain on db, producing its own
+// blocks so they differ from c's
+func (c *testChain) fork(db storage.Database) *testChain {
+	cm, err := NewChainManager(c.config, db)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return &testChain{t: c.t, config: c.config, cm: cm, keys: c.keys, addrs: c.addrs, producer: newTestKey(c.t)}
+}
+
+func newTestKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func keyAddress(key *ecdsa.PrivateKey) string {
+	return crypto.PubkeyToAddress(key.PublicKey).Hex()
+}
+
+// transfer signs a transfer of value from account i to address
+func (c *testChain) transfer(i int, nonce uint64, to string, value Amount) Transaction {
+	maxFee, priorityFee := c.cm.SuggestFees()
+	tx := Transaction{
+		ChainID:              c.config.ChainID,
+		Nonce:                nonce,
+		From:                 c.addrs[i],
+		To:                   to,
+		Value:                value,
+		GasLimit:             TxGas,
+		MaxFeePerGas:         maxFee,
+		MaxPriorityFeePerGas: priorityFee,
+		Timestamp:            1,
+	}
+	if err := tx.Sign(c.keys[i]); err != nil {
+		c.t.Fatal(err)
+	}
+	return tx
+}
+
+// build seals and signs a block with txs on top of the head
+func (c *testChain) build(txs ...Transaction) *Block {
+	c.t.Helper()
+	head := c.cm.GetLatestBlock()
+	timestamp := head.Header.Timestamp + 1
+	key := c.producer
+	if proposer := c.cm.ProposerAt(timestamp); proposer != "" {
+		key = c.keys[c.index(proposer)]
+	}
+	block := NewBlock(head.Header.Height+1, head.Hash(), txs, keyAddress(key))
+	block.Header.Timestamp = timestamp
+	if err := c.cm.SealStateRoot(block); err != nil {
+		c.t.Fatal(err)
+	}
+	if err := block.Sign(key); err != nil {
+		c.t.Fatal(err)
+	}
+	return block
+}
+
+// index returns the account number of address
+func (c *testChain) index(address string) int {
+	for i, addr := range c.addrs {
+		if addr == address {
+			return i
+		}
+	}
+	c.t.Fatalf("no key for %s", address)
+	return -1
+}
+
+// mine builds a block with txs and adds it
+func (c *testChain) mine(txs ...Transaction) *Block {
+	c.t.Helper()
+	block := c.build(txs...)
+	if err := c.cm.AddBlock(block); err != nil {
+		c.t.Fatal(err)
+	}
+	return block
+}
+
+// chainState is what reverting has to restore
+type chainState struct {
+	head     string
+	root     string
+	accounts map[string]AccountState
+	supply   Supply
+}
+
+func (c *testChain) state() chainState {
+	c.t.Helper()
+	root, err := c.cm.stateTrie.Hash()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	accounts := make(map[string]AccountState, len(c.cm.State))
+	for address, account := range c.cm.State {
+		accounts[address] = account
+	}
+	return chainState{c.cm.GetLatestBlock().Hash(), root, accounts, c.cm.GetSupply()}
+}
+
+func (c *testChain) requireState(want chainState) {
+	c.t.Helper()
+	got := c.state()
+	if got.head != want.head || got.root != want.root {
+		c.t.Fatalf("head %s root %s, want head %s root %s", got.head, got.root, want.head, want.root)
+	}
+	if len(got.accounts) != len(want.accounts) {
+		c.t.Fatalf("%d accounts, want %d", len(got.accounts), len(want.accounts))
+	}
+	for address, account := range want.accounts {
+		if got := got.accounts[address]; got.Balance != account.Balance || got.Nonce != account.Nonce || got.Stake != account.Stake {
+			c.t.Fatalf("%s has balance %s nonce %d stake %s, want %s %d %s", address, got.Balance, got.Nonce, got.Stake, account.Balance, account.Nonce, account.Stake)
+		}
+	}
+	if got.supply != want.supply {
+		c.t.Fatalf("supply %+v, want %+v", got.supply, want.supply)
+	}
+}
+
+func TestRevertBlocksRestoresState(t *testing.T) {
+	newAccount := keyAddress(newTestKey(t))
+	tests := []struct {
+		name   string
+		blocks func(c *testChain) [][]Transaction
+	}{
+		{"empty block", func(c *testChain) [][]Transaction {
+			return [][]Transaction{nil}
+		}},
+		{"transfer creating an account", func(c *testChain) [][]Transaction {
+			return [][]Transaction{{c.transfer(0, 0, newAccount, NUSA(1))}}
+		}},
+		{"transfers between existing accounts", func(c *testChain) [][]Transaction {
+			return [][]Transaction{{c.transfer(0, 0, c.addrs[1], NUSA(3)), c.transfer(1, 0, c.addrs[0], NUSA(1))}}
+		}},
+		{"several blocks", func(c *testChain) [][]Transaction {
+			return [][]Transaction{
+				{c.transfer(0, 0, newAccount, NUSA(1))},
+				{c.transfer(0, 1, newAccount, NUSA(1)), c.transfer(1, 0, newAccount, NUSA(2))},
+				nil,
+			}
+		}},
+		{"failed transfer", func(c *testChain) [][]Transaction {
+			return [][]Transaction{{c.transfer(0, 0, newAccount, NUSA(1000))}}
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := newTestChain(t, 2)
+			c.mine()
+			before := c.state()
+
+			blocks := test.blocks(c)
+			for _, txs := range blocks {
+				c.mine(txs...)
+			}
+			reverted, err := c.cm.RevertBlocks(len(blocks))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(reverted) != len(blocks) {
+				t.Fatalf("reverted %d blocks, want %d", len(reverted), len(blocks))
+			}
+			c.requireState(before)
+
+			// The reverted blocks apply again
+			for i := len(reverted) - 1; i >= 0; i-- {
+				if err := c.cm.AddBlock(reverted[i]); err != nil {
+					t.Fatalf("re-adding block #%d: %v", reverted[i].Header.Height, err)
+				}
+			}
+		})
+	}
+}
+
+func TestFailedBlockLeavesStateUntouched(t *testing.T) {
+	newAccount := keyAddress(newTestKey(t))
+	c := newTestChain(t, 2)
+	c.mine(c.transfer(0, 0, c.addrs[1], NUSA(1)))
+	before := c.state()
+
+	tests := []struct {
+		name string
+		txs  []Transaction
+	}{
+		{"nonce gap after a valid transfer", []Transaction{c.transfer(0, 1, newAccount, NUSA(1)), c.transfer(0, 3, newAccount, NUSA(1))}},
+		{"replayed nonce after a valid transfer", []Transaction{c.transfer(1, 0, newAccount, NUSA(1)), c.transfer(0, 0, newAccount, NUSA(1))}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			head := c.cm.GetLatestBlock()
+			block := NewBlock(head.Header.Height+1, head.Hash(), test.txs, keyAddress(c.producer))
+			block.Header.Timestamp = head.Header.Timestamp + 1
+			if err := block.Sign(c.producer); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.cm.AddBlock(block); err == nil || !strings.Contains(err.Error(), "nonce") {
+				t.Fatalf("block added or rejected for another reason: %v", err)
+			}
+			c.requireState(before)
+		})
+	}
+}
+
+func TestRevertBlocksPersists(t *testing.T) {
+	dir := t.TempDir()
+	db, err := storage.NewLevelDB(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newTestChain(t, 2).fork(db)
+	c.mine()
+	before := c.state()
+	c.mine(c.transfer(0, 0, c.addrs[1], NUSA(5)))
+	if _, err := c.cm.RevertBlocks(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.cm.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = storage.NewLevelDB(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reopened := c.fork(db)
+	defer reopened.cm.Close()
+	reopened.requireState(before)
+}
+
+func TestRevertBlocksStopsAtGenesis(t *testing.T) {
+	c := newTestChain(t, 1)
+	c.mine()
+	reverted, err := c.cm.RevertBlocks(2)
+	if err == nil {
+		t.Fatal("reverted genesis")
+	}
+	if height := c.cm.GetLatestBlock().Header.Height; len(reverted) != 1 || height != 0 {
+		t.Fatalf("reverted %d blocks to height %d, want 1 to genesis", len(reverted), height)
+	}
+}
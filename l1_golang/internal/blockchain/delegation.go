@@ -0,0 +1,289 @@
+package blockchain
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+const (
+	MaxCommission  = 10000 // basis points, 100%
+	MaxDelegations = 256   // delegators per validator
+)
+
+// Delegation is stake a holder bonds to a validator. It adds to the
+// validator's voting power, and every block reward the validator earns is
+// split pro rata over its own stake and its delegations; the validator
+// keeps its commission, in basis points, of each delegator's share.
+// Delegations are stored on the validator's account.
+type Delegation struct {
+	Delegator string `json:"delegator"`
+	Amount    Amount `json:"amount"`
+	Rewards   Amount `json:"rewards"` // accrued, paid out by TxTypeClaimRewards
+}
+
+// DelegationInfo is one delegation as seen from the delegator
+type DelegationInfo struct {
+	Validator  string `json:"validator"`
+	Commission uint16 `json:"commission"`
+	Amount     Amount `json:"amount"`
+	Rewards    Amount `json:"rewards"`
+}
+
+// GetDelegations returns the delegations address holds at the head,
+// sorted by validator
+func (cm *ChainManager) GetDelegations(address string) []DelegationInfo {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	address = CanonicalAddress(address)
+	delegations := []DelegationInfo{}
+	for validator := range cm.delegated[address] {
+		account := cm.State[validator]
+		if i := findDelegation(account.Delegations, address); i >= 0 {
+			delegations = append(delegations, DelegationInfo{
+				Validator:  validator,
+				Commission: account.Commission,
+				Amount:     account.Delegations[i].Amount,
+				Rewards:    account.Delegations[i].Rewards,
+			})
+		}
+	}
+	sort.Slice(delegations, func(i, j int) bool { return delegations[i].Validator < delegations[j].Validator })
+	return delegations
+}
+
+// GetValidator returns a registered validator and its delegations
+func (cm *ChainManager) GetValidator(address string) (*ValidatorInfo, []Delegation, error) {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	address = CanonicalAddress(address)
+	account, exists := cm.State[address]
+	if !exists || !account.Validator {
+		return nil, nil, fmt.Errorf("%s is not a validator", address)
+	}
+	info := validatorInfo(address, account)
+	return &info, append([]Delegation{}, account.Delegations...), nil
+}
+
+func validateDelegationChange(tx *Transaction) error {
+	if tx.To == "" || len(tx.Data) != 0 {
+		return fmt.Errorf("delegations take a validator and a value")
+	}
+	if tx.To == tx.From {
+		return fmt.Errorf("validators stake instead of delegating to themselves")
+	}
+	if tx.Value.IsZero() {
+		return fmt.Errorf("delegation change of zero value")
+	}
+	return nil
+}
+
+func (cm *ChainManager) executeDelegate(state *stateDB, header *BlockHeader, tx *Transaction) ([]Log, error) {
+	validator, _ := state.getAccount(tx.To)
+	if !validator.Validator {
+		return nil, fmt.Errorf("%s is not a validator", tx.To)
+	}
+	delegator, _ := state.getAccount(tx.From)
+	balance, err := delegator.Balance.Sub(tx.Value)
+	if err != nil {
+		return nil, fmt.Errorf("insufficient balance to delegate %s", tx.Value)
+	}
+
+	delegations := copyDelegations(validator.Delegations)
+	i := findDelegation(delegations, tx.From)
+	if i < 0 {
+		if len(delegations) >= MaxDelegations {
+			return nil, fmt.Errorf("%s already has %d delegators", tx.To, MaxDelegations)
+		}
+		delegations = append(delegations, Delegation{Delegator: tx.From})
+		i = len(delegations) - 1
+	}
+	if delegations[i].Amount, err = delegations[i].Amount.Add(tx.Value); err != nil {
+		return nil, err
+	}
+
+	delegator.Balance = balance
+	validator.Delegations = delegations
+	state.setAccount(tx.From, delegator)
+	state.setAccount(tx.To, validator)
+	return []Log{delegationLog(DelegateTopic, tx, tx.Value)}, nil
+}
+
+// Undelegated stake goes through the sender's unbonding queue. Closing a
+// delegation also pays out its accrued rewards. The log data is the
+// value followed by the 8-byte release height.
+func (cm *ChainManager) executeUndelegate(state *stateDB, header *BlockHeader, tx *Transaction) ([]Log, error) {
+	validator, _ := state.getAccount(tx.To)
+	delegations := copyDelegations(validator.Delegations)
+	i := findDelegation(delegations, tx.From)
+	if i < 0 {
+		return nil, fmt.Errorf("no delegation from %s to %s", tx.From, tx.To)
+	}
+	amount, err := delegations[i].Amount.Sub(tx.Value)
+	if err != nil {
+		return nil, fmt.Errorf("delegation %s below %s", delegations[i].Amount, tx.Value)
+	}
+
+	delegator, _ := state.getAccount(tx.From)
+	release := header.Height + cm.config.UnbondingPeriod
+	if err := addUnbonding(&delegator, tx.Value, release); err != nil {
+		return nil, err
+	}
+	delegations[i].Amount = amount
+	if amount.IsZero() {
+		if delegator.Balance, err = delegator.Balance.Add(delegations[i].Rewards); err != nil {
+			return nil, err
+		}
+		delegations = append(delegations[:i], delegations[i+1:]...)
+	}
+
+	validator.Delegations = delegations
+	state.setAccount(tx.From, delegator)
+	state.setAccount(tx.To, validator)
+
+	log := delegationLog(UndelegateTopic, tx, tx.Value)
+	log.Data = binary.BigEndian.AppendUint64(log.Data, release)
+	return []Log{log}, nil
+}
+
+func validateClaimRewards(tx *Transaction) error {
+	if tx.To == "" || !tx.Value.IsZero() || len(tx.Data) != 0 {
+		return fmt.Errorf("reward claims take only a validator")
+	}
+	return nil
+}
+
+func (cm *ChainManager) executeClaimRewards(state *stateDB, header *BlockHeader, tx *Transaction) ([]Log, error) {
+	validator, _ := state.getAccount(tx.To)
+	delegations := copyDelegations(validator.Delegations)
+	i := findDelegation(delegations, tx.From)
+	if i < 0 {
+		return nil, fmt.Errorf("no delegation from %s to %s", tx.From, tx.To)
+	}
+	rewards := delegations[i].Rewards
+	if rewards.IsZero() {
+		return nil, fmt.Errorf("no rewards accrued")
+	}
+
+	delegator, _ := state.getAccount(tx.From)
+	balance, err := delegator.Balance.Add(rewards)
+	if err != nil {
+		return nil, err
+	}
+	delegator.Balance = balance
+	delegations[i].Rewards = Amount{}
+	validator.Delegations = delegations
+	state.setAccount(tx.From, delegator)
+	state.setAccount(tx.To, validator)
+	return []Log{delegationLog(ClaimRewardsTopic, tx, rewards)}, nil
+}
+
+// The Data of TxTypeSetCommission is the commission in basis points as a
+// 2-byte big-endian integer
+func validateSetCommission(tx *Transaction) error {
+	if tx.To != "" || !tx.Value.IsZero() || len(tx.Data) != 2 {
+		return fmt.Errorf("commission changes take only a 2-byte commission")
+	}
+	if commission := binary.BigEndian.Uint16(tx.Data); commission > MaxCommission {
+		return fmt.Errorf("commission %d above %d basis points", commission, MaxCommission)
+	}
+	return nil
+}
+
+func (cm *ChainManager) executeSetCommission(state *stateDB, header *BlockHeader, tx *Transaction) ([]Log, error) {
+	account, _ := state.getAccount(tx.From)
+	if !account.Validator {
+		return nil, fmt.Errorf("%s is not a validator", tx.From)
+	}
+	account.Commission = binary.BigEndian.Uint16(tx.Data)
+	state.setAccount(tx.From, account)
+	return []Log{{Address: tx.From, Topics: []string{CommissionTopic, tx.From}, Data: tx.Data}}, nil
+}
+
+// splitReward credits the delegators' part of reward to their delegations
+// and returns the validator's part: its own stake's share, the commission
+// on every delegator's share and the rounding remainder
+func splitReward(account *AccountState, reward Amount) (Amount, error) {
+	if len(account.Delegations) == 0 || reward.IsZero() {
+		return reward, nil
+	}
+
+	bonded := bondedStake(*account)
+	delegations := copyDelegations(account.Delegations)
+	left := reward
+	for i := range delegations {
+		share := reward.Portion(delegations[i].Amount, bonded)
+		share, _ = share.Sub(share.MulDiv(uint64(account.Commission), MaxCommission))
+		rewards, err := delegations[i].Rewards.Add(share)
+		if err != nil {
+			return Amount{}, fmt.Errorf("delegator reward: %v", err)
+		}
+		delegations[i].Rewards = rewards
+		// The shares never add up to more than reward
+		left, _ = left.Sub(share)
+	}
+	account.Delegations = delegations
+	return left, nil
+}
+
+// Stake backing the account as a validator: its own and all delegated
+func bondedStake(account AccountState) Amount {
+	bonded := account.Stake
+	for _, delegation := range account.Delegations {
+		bonded, _ = bonded.Add(delegation.Amount)
+	}
+	return bonded
+}
+
+// NUSA the account holds for its delegators, bonded and accrued
+func delegationsTotal(account AccountState) Amount {
+	total := Amount{}
+	for _, delegation := range account.Delegations {
+		total, _ = total.Add(delegation.Amount)
+		total, _ = total.Add(delegation.Rewards)
+	}
+	return total
+}
+
+// indexDelegations moves validator's entries in cm.delegated from the
+// delegators of old, its account until now, to those of account
+func (cm *ChainManager) indexDelegations(validator string, old, account AccountState) {
+	for _, delegation := range old.Delegations {
+		delete(cm.delegated[delegation.Delegator], validator)
+		if len(cm.delegated[delegation.Delegator]) == 0 {
+			delete(cm.delegated, delegation.Delegator)
+		}
+	}
+	for _, delegation := range account.Delegations {
+		if cm.delegated[delegation.Delegator] == nil {
+			cm.delegated[delegation.Delegator] = make(map[string]bool)
+		}
+		cm.delegated[delegation.Delegator][validator] = true
+	}
+}
+
+func findDelegation(delegations []Delegation, delegator string) int {
+	for i := range delegations {
+		if delegations[i].Delegator == delegator {
+			return i
+		}
+	}
+	return -1
+}
+
+// Copy so the delegations of the committed account are left untouched
+func copyDelegations(delegations []Delegation) []Delegation {
+	return append([]Delegation(nil), delegations...)
+}
+
+// Log with the delegator and validator as topics and a 32-byte value
+func delegationLog(topic string, tx *Transaction, value Amount) Log {
+	data := value.int().Bytes32()
+	return Log{
+		Address: tx.From,
+		Topics:  []string{topic, tx.From, tx.To},
+		Data:    data[:],
+	}
+}
@@ -0,0 +1,115 @@
+package blockchain
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitReward(t *testing.T) {
+	delegations := func(amounts ...Amount) []Delegation {
+		var list []Delegation
+		for i, amount := range amounts {
+			list = append(list, Delegation{Delegator: testHashes(i + 1)[i], Amount: amount})
+		}
+		return list
+	}
+	tests := []struct {
+		name       string
+		account    AccountState
+		reward     Amount
+		validator  Amount
+		delegators []Amount
+	}{
+		{"no delegators", AccountState{Stake: NUSA(10)}, NUSA(2), NUSA(2), nil},
+		{"no reward", AccountState{Stake: NUSA(10), Delegations: delegations(NUSA(30))}, Amount{}, Amount{}, []Amount{{}}},
+		{"commission 0", AccountState{Stake: NUSA(10), Delegations: delegations(NUSA(30))}, NUSA(4), NUSA(1), []Amount{NUSA(3)}},
+		{"commission 10000", AccountState{Stake: NUSA(10), Commission: MaxCommission, Delegations: delegations(NUSA(30))}, NUSA(4), NUSA(4), []Amount{{}}},
+		{"several delegators", AccountState{Stake: NUSA(40), Commission: 1000, Delegations: delegations(NUSA(10), NUSA(20), NUSA(30))},
+			NewAmount(100), NewAmount(46), []Amount{NewAmount(9), NewAmount(18), NewAmount(27)}},
+		{"rounding dust", AccountState{Stake: NewAmount(1), Delegations: delegations(NewAmount(1), NewAmount(1))},
+			NewAmount(10), NewAmount(4), []Amount{NewAmount(3), NewAmount(3)}},
+		{"dust and commission", AccountState{Stake: NewAmount(1), Commission: 5000, Delegations: delegations(NewAmount(1), NewAmount(1))},
+			NewAmount(10), NewAmount(6), []Amount{NewAmount(2), NewAmount(2)}},
+	}
+	for _, test := range tests {
+		account := test.account
+		validator, err := splitReward(&account, test.reward)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		var delegators []Amount
+		total := validator
+		for _, delegation := range account.Delegations {
+			delegators = append(delegators, delegation.Rewards)
+			total, _ = total.Add(delegation.Rewards)
+		}
+		if validator != test.validator || !reflect.DeepEqual(delegators, test.delegators) {
+			t.Errorf("%s: validator %s delegators %v, want %s %v", test.name, validator, delegators, test.validator, test.delegators)
+		}
+		if total != test.reward {
+			t.Errorf("%s: parts add up to %s of %s", test.name, total, test.reward)
+		}
+		for i := range test.account.Delegations {
+			if !test.account.Delegations[i].Rewards.IsZero() {
+				t.Fatalf("%s: committed delegations modified", test.name)
+			}
+		}
+	}
+}
+
+func TestDelegationLifecycle(t *testing.T) {
+	c := newTestChain(t, 2)
+	c.config.GenesisAccounts[0].Stake = NUSA(10)
+	c.config.GenesisAccounts[0].Validator = true
+	c.config.UnbondingPeriod = 100
+	c = c.fork(nil)
+	validator, delegator := c.addrs[0], c.addrs[1]
+
+	delegation := func() DelegationInfo {
+		t.Helper()
+		delegations := c.cm.GetDelegations(delegator)
+		if len(delegations) != 1 || delegations[0].Validator != validator {
+			t.Fatalf("delegations %+v", delegations)
+		}
+		return delegations[0]
+	}
+
+	c.mine(c.typed(1, 0, TxTypeDelegate, validator, NUSA(30)))
+	before := delegation()
+	if before.Amount != NUSA(30) {
+		t.Fatalf("delegated %s", before.Amount)
+	}
+	c.mine()
+	after := delegation()
+	share := c.config.BlockReward.Portion(NUSA(30), NUSA(40))
+	if accrued, _ := after.Rewards.Sub(before.Rewards); accrued != share {
+		t.Errorf("accrued %s in a block, want %s", accrued, share)
+	}
+
+	balance := c.cm.GetBalance(delegator)
+	claim := c.typed(1, 1, TxTypeClaimRewards, validator, Amount{})
+	block := c.mine(claim)
+	fee, _ := claim.EffectiveGasPrice(block.Header.BaseFee).MulUint64(TxGas)
+	want, _ := balance.Add(after.Rewards)
+	want, _ = want.Sub(fee)
+	if got := c.cm.GetBalance(delegator); got != want {
+		t.Errorf("balance %s after claiming, want %s", got, want)
+	}
+
+	// Closing the delegation drops it from the delegator's list, and a
+	// revert brings it back
+	c.mine(c.typed(1, 2, TxTypeUndelegate, validator, NUSA(30)))
+	if delegations := c.cm.GetDelegations(delegator); len(delegations) != 0 {
+		t.Errorf("delegations %+v after undelegating", delegations)
+	}
+	if account := c.cm.State[delegator]; len(account.Unbonding) != 1 || account.Unbonding[0].Amount != NUSA(30) {
+		t.Errorf("unbonding %+v after undelegating", account.Unbonding)
+	}
+	if _, err := c.cm.RevertBlocks(1); err != nil {
+		t.Fatal(err)
+	}
+	if delegation().Amount != NUSA(30) {
+		t.Error("reverted undelegation not restored")
+	}
+}
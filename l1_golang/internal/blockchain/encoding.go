@@ -0,0 +1,349 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Canonical binary encoding used for hashing, signing, storage and the wire.
+// Every encoding is a version byte followed by an RLP list; fields are
+// listed in the order below and new fields are only ever appended.
+const EncodingVersion byte = 1
+
+type rlpHeader struct {
+	Version    uint64
+	Height     uint64
+	Timestamp  uint64
+	PrevHash   string
+	MerkleRoot string
+	StateRoot  string
+	Validator  string
+	Nonce      uint64
+	Difficulty uint64
+	GasLimit   uint64
+	GasUsed    uint64
+	Reward     Amount
+	ExtraData  string
+
+	// Appended fields are optional so older encodings still decode
+	ReceiptsRoot string `rlp:"optional"`
+	BaseFee      Amount `rlp:"optional"`
+}
+
+// Fields covered by the transaction hash and therefore by its signature
+type rlpTxPayload struct {
+	Nonce     uint64
+	From      string
+	To        string
+	Value     Amount
+	GasPrice  Amount
+	GasLimit  uint64
+	Data      []byte
+	Timestamp uint64
+
+	MaxFeePerGas         Amount `rlp:"optional"`
+	MaxPriorityFeePerGas Amount `rlp:"optional"`
+	Type                 uint8  `rlp:"optional"`
+	ChainID              uint64 `rlp:"optional"`
+}
+
+type rlpSig struct {
+	R []byte
+	S []byte
+	V uint8
+}
+
+type rlpTx struct {
+	Payload   rlpTxPayload
+	Signature rlpSig
+}
+
+type rlpLog struct {
+	Address string
+	Topics  []string
+	Data    []byte
+}
+
+// Consensus fields of a receipt; block position and error text are excluded
+type rlpReceipt struct {
+	TxHash            string
+	Status            uint64
+	GasUsed           uint64
+	CumulativeGasUsed uint64
+	Fee               Amount
+	Logs              []rlpLog
+}
+
+type rlpBlock struct {
+	Header       rlpHeader
+	Transactions []rlpTx
+	Signature    string
+}
+
+// Fields covered by the vote hash and therefore by its signature
+type rlpVotePayload struct {
+	Type      uint8
+	Height    uint64
+	Round     uint64
+	BlockHash string
+	Validator string
+}
+
+type rlpVote struct {
+	Payload   rlpVotePayload
+	Signature string
+}
+
+func EncodeHeader(h *BlockHeader) ([]byte, error) {
+	return encodeVersioned(headerToRLP(h))
+}
+
+func DecodeHeader(data []byte) (*BlockHeader, error) {
+	var enc rlpHeader
+	if err := decodeVersioned(data, &enc); err != nil {
+		return nil, fmt.Errorf("invalid header encoding: %v", err)
+	}
+	header := headerFromRLP(enc)
+	return &header, nil
+}
+
+func EncodeTransaction(tx *Transaction) ([]byte, error) {
+	enc, err := txToRLP(tx)
+	if err != nil {
+		return nil, err
+	}
+	return encodeVersioned(enc)
+}
+
+// DecodeTransaction restores a transaction; its hash is recomputed from the payload
+func DecodeTransaction(data []byte) (*Transaction, error) {
+	var enc rlpTx
+	if err := decodeVersioned(data, &enc); err != nil {
+		return nil, fmt.Errorf("invalid transaction encoding: %v", err)
+	}
+	tx := txFromRLP(enc)
+	return &tx, nil
+}
+
+func EncodeSignature(sig TransactionSig) ([]byte, error) {
+	enc, err := sigToRLP(sig)
+	if err != nil {
+		return nil, err
+	}
+	return encodeVersioned(enc)
+}
+
+func DecodeSignature(data []byte) (TransactionSig, error) {
+	var enc rlpSig
+	if err := decodeVersioned(data, &enc); err != nil {
+		return TransactionSig{}, fmt.Errorf("invalid signature encoding: %v", err)
+	}
+	return sigFromRLP(enc), nil
+}
+
+func EncodeBlock(b *Block) ([]byte, error) {
+	enc := rlpBlock{
+		Header:       headerToRLP(&b.Header),
+		Transactions: make([]rlpTx, len(b.Transactions)),
+		Signature:    b.Signature,
+	}
+	for i := range b.Transactions {
+		tx, err := txToRLP(&b.Transactions[i])
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d: %v", i, err)
+		}
+		enc.Transactions[i] = tx
+	}
+	return encodeVersioned(enc)
+}
+
+func DecodeBlock(data []byte) (*Block, error) {
+	var enc rlpBlock
+	if err := decodeVersioned(data, &enc); err != nil {
+		return nil, fmt.Errorf("invalid block encoding: %v", err)
+	}
+
+	block := &Block{
+		Header:       headerFromRLP(enc.Header),
+		Transactions: make([]Transaction, len(enc.Transactions)),
+		Signature:    enc.Signature,
+	}
+	for i, tx := range enc.Transactions {
+		block.Transactions[i] = txFromRLP(tx)
+	}
+	return block, nil
+}
+
+func EncodeReceipt(r *Receipt) ([]byte, error) {
+	enc := rlpReceipt{
+		TxHash:            r.TxHash,
+		Status:            r.Status,
+		GasUsed:           r.GasUsed,
+		CumulativeGasUsed: r.CumulativeGasUsed,
+		Fee:               r.Fee,
+		Logs:              make([]rlpLog, len(r.Logs)),
+	}
+	for i, log := range r.Logs {
+		enc.Logs[i] = rlpLog{Address: log.Address, Topics: log.Topics, Data: log.Data}
+	}
+	return encodeVersioned(enc)
+}
+
+func EncodeVote(v *Vote) ([]byte, error) {
+	return encodeVersioned(rlpVote{Payload: votePayload(v), Signature: v.Signature})
+}
+
+func DecodeVote(data []byte) (*Vote, error) {
+	var enc rlpVote
+	if err := decodeVersioned(data, &enc); err != nil {
+		return nil, fmt.Errorf("invalid vote encoding: %v", err)
+	}
+	return &Vote{
+		Type:      enc.Payload.Type,
+		Height:    enc.Payload.Height,
+		Round:     enc.Payload.Round,
+		BlockHash: enc.Payload.BlockHash,
+		Validator: enc.Payload.Validator,
+		Signature: enc.Signature,
+	}, nil
+}
+
+func encodeVersioned(val interface{}) ([]byte, error) {
+	payload, err := rlp.EncodeToBytes(val)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{EncodingVersion}, payload...), nil
+}
+
+func decodeVersioned(data []byte, val interface{}) error {
+	if len(data) == 0 {
+		return fmt.Errorf("empty input")
+	}
+	if data[0] != EncodingVersion {
+		return fmt.Errorf("unsupported encoding version %d", data[0])
+	}
+	return rlp.DecodeBytes(data[1:], val)
+}
+
+func headerToRLP(h *BlockHeader) rlpHeader {
+	return rlpHeader{
+		Version:    h.Version,
+		Height:     h.Height,
+		Timestamp:  uint64(h.Timestamp),
+		PrevHash:   h.PrevHash,
+		MerkleRoot: h.MerkleRoot,
+		StateRoot:  h.StateRoot,
+		Validator:  h.Validator,
+		Nonce:      h.Nonce,
+		Difficulty: h.Difficulty,
+		GasLimit:   h.GasLimit,
+		GasUsed:    h.GasUsed,
+		Reward:     h.Reward,
+		ExtraData:  h.ExtraData,
+
+		ReceiptsRoot: h.ReceiptsRoot,
+		BaseFee:      h.BaseFee,
+	}
+}
+
+func headerFromRLP(enc rlpHeader) BlockHeader {
+	return BlockHeader{
+		Version:    enc.Version,
+		Height:     enc.Height,
+		Timestamp:  int64(enc.Timestamp),
+		PrevHash:   enc.PrevHash,
+		MerkleRoot: enc.MerkleRoot,
+		StateRoot:  enc.StateRoot,
+		Validator:  enc.Validator,
+		Nonce:      enc.Nonce,
+		Difficulty: enc.Difficulty,
+		GasLimit:   enc.GasLimit,
+		GasUsed:    enc.GasUsed,
+		Reward:     enc.Reward,
+		ExtraData:  enc.ExtraData,
+
+		ReceiptsRoot: enc.ReceiptsRoot,
+		BaseFee:      enc.BaseFee,
+	}
+}
+
+func txPayload(tx *Transaction) rlpTxPayload {
+	return rlpTxPayload{
+		Nonce:     tx.Nonce,
+		From:      tx.From,
+		To:        tx.To,
+		Value:     tx.Value,
+		GasPrice:  tx.GasPrice,
+		GasLimit:  tx.GasLimit,
+		Data:      tx.Data,
+		Timestamp: uint64(tx.Timestamp),
+
+		MaxFeePerGas:         tx.MaxFeePerGas,
+		MaxPriorityFeePerGas: tx.MaxPriorityFeePerGas,
+		Type:                 tx.Type,
+		ChainID:              tx.ChainID,
+	}
+}
+
+func votePayload(v *Vote) rlpVotePayload {
+	return rlpVotePayload{
+		Type:      v.Type,
+		Height:    v.Height,
+		Round:     v.Round,
+		BlockHash: v.BlockHash,
+		Validator: v.Validator,
+	}
+}
+
+func txToRLP(tx *Transaction) (rlpTx, error) {
+	sig, err := sigToRLP(tx.Signature)
+	if err != nil {
+		return rlpTx{}, err
+	}
+	return rlpTx{Payload: txPayload(tx), Signature: sig}, nil
+}
+
+func txFromRLP(enc rlpTx) Transaction {
+	tx := Transaction{
+		Type:      enc.Payload.Type,
+		ChainID:   enc.Payload.ChainID,
+		Nonce:     enc.Payload.Nonce,
+		From:      enc.Payload.From,
+		To:        enc.Payload.To,
+		Value:     enc.Payload.Value,
+		GasPrice:  enc.Payload.GasPrice,
+		GasLimit:  enc.Payload.GasLimit,
+		Data:      enc.Payload.Data,
+		Timestamp: int64(enc.Payload.Timestamp),
+		Signature: sigFromRLP(enc.Signature),
+
+		MaxFeePerGas:         enc.Payload.MaxFeePerGas,
+		MaxPriorityFeePerGas: enc.Payload.MaxPriorityFeePerGas,
+	}
+	tx.Hash = tx.CalculateHash()
+	return tx
+}
+
+func sigToRLP(sig TransactionSig) (rlpSig, error) {
+	r, err := hex.DecodeString(strings.TrimPrefix(sig.R, "0x"))
+	if err != nil {
+		return rlpSig{}, fmt.Errorf("invalid signature r: %v", err)
+	}
+	s, err := hex.DecodeString(strings.TrimPrefix(sig.S, "0x"))
+	if err != nil {
+		return rlpSig{}, fmt.Errorf("invalid signature s: %v", err)
+	}
+	return rlpSig{R: r, S: s, V: sig.V}, nil
+}
+
+func sigFromRLP(enc rlpSig) TransactionSig {
+	return TransactionSig{
+		R: hex.EncodeToString(enc.R),
+		S: hex.EncodeToString(enc.S),
+		V: enc.V,
+	}
+}
@@ -0,0 +1,214 @@
+package blockchain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+type encodingVector struct {
+	Name     string          `json:"name"`
+	Kind     string          `json:"kind"`
+	Value    json.RawMessage `json:"value"`
+	Encoding string          `json:"encoding"`
+	Hash     string          `json:"hash"`
+}
+
+// decodedVector is what a vector's encoding decodes to
+type decodedVector struct {
+	encoding []byte   // the decoded value encoded again
+	hash     string   // "" for kinds without a hash
+	signers  []signer // signatures it carries
+}
+
+type signer struct {
+	what    string
+	address string
+	recover func() (common.Address, error)
+}
+
+// Every vector in docs/encoding_vectors.json must decode, encode back to
+// the same bytes, hash as recorded and carry signatures by the key the
+// file names. Its JSON value must encode to the same bytes as well.
+func TestEncodingVectors(t *testing.T) {
+	data, err := os.ReadFile("../../docs/encoding_vectors.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file struct {
+		EncodingVersion  byte             `json:"encoding_version"`
+		SignerPrivateKey string           `json:"signer_private_key"`
+		Vectors          []encodingVector `json:"vectors"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		t.Fatal(err)
+	}
+	if file.EncodingVersion != EncodingVersion {
+		t.Fatalf("vectors are version %d, encoding is version %d", file.EncodingVersion, EncodingVersion)
+	}
+	key, err := crypto.HexToECDSA(file.SignerPrivateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signerAddress := crypto.PubkeyToAddress(key.PublicKey)
+
+	kinds := make(map[string]bool)
+	for _, vector := range file.Vectors {
+		kinds[vector.Kind] = true
+		t.Run(vector.Name, func(t *testing.T) {
+			raw, err := hex.DecodeString(vector.Encoding)
+			if err != nil {
+				t.Fatal(err)
+			}
+			decoded, err := decodeVector(vector.Kind, raw, vector.Value)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if !bytes.Equal(decoded.encoding, raw) {
+				t.Fatalf("re-encoded to %x", decoded.encoding)
+			}
+			if decoded.hash != vector.Hash {
+				t.Fatalf("hash %s, want %s", decoded.hash, vector.Hash)
+			}
+			for _, s := range decoded.signers {
+				recovered, err := s.recover()
+				if err != nil {
+					t.Fatalf("%s: %v", s.what, err)
+				}
+				if recovered != signerAddress || s.address != signerAddress.Hex() {
+					t.Fatalf("%s signed by %s for %s, want %s", s.what, recovered.Hex(), s.address, signerAddress.Hex())
+				}
+			}
+
+			fromJSON, err := encodeVectorValue(vector.Kind, vector.Value)
+			if err != nil {
+				t.Fatalf("encode value: %v", err)
+			}
+			if !bytes.Equal(fromJSON, raw) {
+				t.Fatalf("value encodes to %x", fromJSON)
+			}
+		})
+	}
+	for _, kind := range []string{"BlockHeader", "Transaction", "TransactionSig", "Receipt", "Block", "Vote"} {
+		if !kinds[kind] {
+			t.Errorf("no %s vector", kind)
+		}
+	}
+}
+
+// decodeVector decodes raw as kind. Receipts are only ever encoded, for
+// their hash, so a receipt vector is checked from its value.
+func decodeVector(kind string, raw []byte, value json.RawMessage) (*decodedVector, error) {
+	var decoded decodedVector
+	var err error
+	switch kind {
+	case "BlockHeader":
+		header, decodeErr := DecodeHeader(raw)
+		if decodeErr != nil {
+			return nil, decodeErr
+		}
+		block := Block{Header: *header}
+		decoded.hash = block.Hash()
+		decoded.encoding, err = EncodeHeader(header)
+	case "Transaction":
+		tx, decodeErr := DecodeTransaction(raw)
+		if decodeErr != nil {
+			return nil, decodeErr
+		}
+		decoded.hash = tx.Hash
+		decoded.signers = txSigners(tx)
+		decoded.encoding, err = EncodeTransaction(tx)
+	case "TransactionSig":
+		sig, decodeErr := DecodeSignature(raw)
+		if decodeErr != nil {
+			return nil, decodeErr
+		}
+		decoded.encoding, err = EncodeSignature(sig)
+	case "Receipt":
+		var receipt Receipt
+		if err := json.Unmarshal(value, &receipt); err != nil {
+			return nil, err
+		}
+		decoded.hash = receipt.Hash()
+		decoded.encoding, err = EncodeReceipt(&receipt)
+	case "Block":
+		block, decodeErr := DecodeBlock(raw)
+		if decodeErr != nil {
+			return nil, decodeErr
+		}
+		if root := block.CalculateMerkleRoot(); root != block.Header.MerkleRoot {
+			return nil, fmt.Errorf("merkle root %s, transactions give %s", block.Header.MerkleRoot, root)
+		}
+		decoded.hash = block.Hash()
+		if block.Signature != "" {
+			decoded.signers = append(decoded.signers, signer{"block", block.Header.Validator, block.Signer})
+		}
+		for i := range block.Transactions {
+			decoded.signers = append(decoded.signers, txSigners(&block.Transactions[i])...)
+		}
+		decoded.encoding, err = EncodeBlock(block)
+	case "Vote":
+		vote, decodeErr := DecodeVote(raw)
+		if decodeErr != nil {
+			return nil, decodeErr
+		}
+		decoded.hash = vote.Hash()
+		decoded.signers = []signer{{"vote", vote.Validator, vote.Signer}}
+		decoded.encoding, err = EncodeVote(vote)
+	default:
+		return nil, fmt.Errorf("unknown kind %q", kind)
+	}
+	return &decoded, err
+}
+
+func txSigners(tx *Transaction) []signer {
+	if tx.Signature == (TransactionSig{}) {
+		return nil
+	}
+	return []signer{{"transaction " + tx.Hash, tx.From, tx.Sender}}
+}
+
+// encodeVectorValue encodes a vector's JSON value as kind
+func encodeVectorValue(kind string, value json.RawMessage) ([]byte, error) {
+	var v interface{}
+	switch kind {
+	case "BlockHeader":
+		v = &BlockHeader{}
+	case "Transaction":
+		v = &Transaction{}
+	case "TransactionSig":
+		v = &TransactionSig{}
+	case "Receipt":
+		v = &Receipt{}
+	case "Block":
+		v = &Block{}
+	case "Vote":
+		v = &Vote{}
+	default:
+		return nil, fmt.Errorf("unknown kind %q", kind)
+	}
+	if err := json.Unmarshal(value, v); err != nil {
+		return nil, err
+	}
+
+	switch v := v.(type) {
+	case *BlockHeader:
+		return EncodeHeader(v)
+	case *Transaction:
+		return EncodeTransaction(v)
+	case *TransactionSig:
+		return EncodeSignature(*v)
+	case *Receipt:
+		return EncodeReceipt(v)
+	case *Block:
+		return EncodeBlock(v)
+	default:
+		return EncodeVote(v.(*Vote))
+	}
+}
@@ -0,0 +1,124 @@
+package blockchain
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Chain exports are a gzip stream: exportMagic, then one record per block,
+// a uvarint length followed by EncodeBlock, in ascending height order.
+var exportMagic = []byte("NUSABLK\x01")
+
+// Upper bound on one encoded block, guarding against corrupt lengths
+const maxExportBlockSize = 32 * 1024 * 1024
+
+// ExportBlocks streams the canonical blocks first..last to w and returns
+// how many were written. The range is taken from the chain as it was when
+// the export started; blocks are never modified once added, so the stream
+// itself runs without the chain lock.
+func (cm *ChainManager) ExportBlocks(w io.Writer, first, last uint64) (int, error) {
+	cm.mutex.RLock()
+	head := cm.latestBlock().Header.Height
+	if first > last || last > head {
+		cm.mutex.RUnlock()
+		return 0, fmt.Errorf("invalid block range %d-%d (head #%d)", first, last, head)
+	}
+	blocks := make([]*Block, last-first+1)
+	copy(blocks, cm.Chain[first:last+1])
+	cm.mutex.RUnlock()
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(exportMagic); err != nil {
+		return 0, err
+	}
+
+	var size [binary.MaxVarintLen64]byte
+	exported := 0
+	for _, block := range blocks {
+		data, err := EncodeBlock(block)
+		if err != nil {
+			return exported, fmt.Errorf("failed to encode block #%d: %v", block.Header.Height, err)
+		}
+		n := binary.PutUvarint(size[:], uint64(len(data)))
+		if _, err := zw.Write(size[:n]); err != nil {
+			return exported, err
+		}
+		if _, err := zw.Write(data); err != nil {
+			return exported, err
+		}
+		exported++
+	}
+	return exported, zw.Close()
+}
+
+// ImportBlocks reads an export and adds each block through AddBlock, so
+// every block is validated and executed as if it came from a peer. Blocks
+// already on the canonical chain are skipped. It returns the number of
+// blocks imported and, on failure, which block was rejected and why.
+func (cm *ChainManager) ImportBlocks(r io.Reader) (int, error) {
+	imported := 0
+	err := readExport(r, func(block *Block) error {
+		hash := block.Hash()
+		cm.mutex.RLock()
+		known, exists := cm.blocks[hash]
+		canonical := exists && cm.isCanonical(known)
+		cm.mutex.RUnlock()
+		if canonical {
+			return nil
+		}
+		if block.Header.Height == 0 {
+			return fmt.Errorf("export genesis %s does not match this chain", hash)
+		}
+
+		if err := cm.AddBlock(block); err != nil {
+			return fmt.Errorf("block #%d %s rejected: %v", block.Header.Height, hash, err)
+		}
+		imported++
+		return nil
+	})
+	return imported, err
+}
+
+// readExport decodes an export and hands each block to fn in order
+func readExport(r io.Reader, fn func(*Block) error) error {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("not a chain export: %v", err)
+	}
+	defer zr.Close()
+
+	reader := bufio.NewReader(zr)
+	magic := make([]byte, len(exportMagic))
+	if _, err := io.ReadFull(reader, magic); err != nil || !bytes.Equal(magic, exportMagic) {
+		return fmt.Errorf("not a chain export")
+	}
+
+	for record := 0; ; record++ {
+		size, err := binary.ReadUvarint(reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("export truncated at record %d: %v", record, err)
+		}
+		if size > maxExportBlockSize {
+			return fmt.Errorf("export record %d too large: %d bytes", record, size)
+		}
+
+		data := make([]byte, size)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return fmt.Errorf("export truncated at record %d: %v", record, err)
+		}
+		block, err := DecodeBlock(data)
+		if err != nil {
+			return fmt.Errorf("export record %d: %v", record, err)
+		}
+		if err := fn(block); err != nil {
+			return err
+		}
+	}
+}
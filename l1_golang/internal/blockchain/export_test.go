@@ -0,0 +1,55 @@
+package blockchain
+
+import (
+	"bytes"
+	"testing"
+)
+
+// mineOnWrite adds a block the first time the export writes to it
+type mineOnWrite struct {
+	bytes.Buffer
+	mine func()
+}
+
+func (w *mineOnWrite) Write(p []byte) (int, error) {
+	if mine := w.mine; mine != nil {
+		w.mine = nil
+		mine()
+	}
+	return w.Buffer.Write(p)
+}
+
+func TestExportImportBlocks(t *testing.T) {
+	c := newTestChain(t, 1)
+	recipient := keyAddress(newTestKey(t))
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		c.mine(c.transfer(0, nonce, recipient, NUSA(1)))
+	}
+	head := c.cm.GetLatestBlock()
+
+	// The chain stays writable while the export streams
+	w := &mineOnWrite{mine: func() { c.mine() }}
+	exported, err := c.cm.ExportBlocks(w, 1, head.Header.Height)
+	if err != nil || exported != 3 {
+		t.Fatalf("exported %d blocks: %v", exported, err)
+	}
+	if c.cm.GetLatestBlock().Header.Height != head.Header.Height+1 {
+		t.Fatal("no block added during the export")
+	}
+
+	other := c.fork(nil)
+	imported, err := other.cm.ImportBlocks(&w.Buffer)
+	if err != nil || imported != 3 {
+		t.Fatalf("imported %d blocks: %v", imported, err)
+	}
+	if got := other.cm.GetLatestBlock(); got.Hash() != head.Hash() {
+		t.Errorf("imported head #%d %s, want %s", got.Header.Height, got.Hash(), head.Hash())
+	}
+
+	if _, err := c.cm.ExportBlocks(&bytes.Buffer{}, 2, 1); err == nil {
+		t.Error("exported a reversed range")
+	}
+	if _, err := c.cm.ExportBlocks(&bytes.Buffer{}, 0, head.Header.Height+2); err == nil {
+		t.Error("exported past the head")
+	}
+}
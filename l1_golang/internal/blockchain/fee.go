@@ -0,0 +1,111 @@
+package blockchain
+
+// Base fee market, after EIP-1559. Each block targets half its gas limit;
+// the base fee moves by up to 1/8 per block towards that target, never
+// dropping below ChainConfig.MinGasPrice. The base fee is burned and only
+// the priority tip reaches the validator.
+const (
+	ElasticityMultiplier     = 2
+	BaseFeeChangeDenominator = 8
+
+	// Tip suggested to wallets, in wei
+	DefaultPriorityFee = 1000000000 // 1 gwei
+)
+
+// CalcBaseFee returns the base fee of the block following parent
+func CalcBaseFee(parent *BlockHeader, minBaseFee Amount) Amount {
+	target := parent.GasLimit / ElasticityMultiplier
+	baseFee := parent.BaseFee
+
+	switch {
+	case target == 0 || parent.GasUsed == target:
+	case parent.GasUsed > target:
+		delta := baseFeeDelta(parent.BaseFee, parent.GasUsed-target, target)
+		if delta.IsZero() {
+			delta = NewAmount(1)
+		}
+		if next, err := baseFee.Add(delta); err == nil {
+			baseFee = next
+		}
+	default:
+		delta := baseFeeDelta(parent.BaseFee, target-parent.GasUsed, target)
+		baseFee, _ = baseFee.Sub(delta)
+	}
+
+	if baseFee.Cmp(minBaseFee) < 0 {
+		return minBaseFee
+	}
+	return baseFee
+}
+
+// baseFeeDelta is baseFee*gasDelta/target/BaseFeeChangeDenominator. The
+// denominator is taken in 256 bits: a gas limit near 2^64 would overflow
+// it as a uint64.
+func baseFeeDelta(baseFee Amount, gasDelta, target uint64) Amount {
+	denominator, _ := NewAmount(target).MulUint64(BaseFeeChangeDenominator)
+	return baseFee.Portion(NewAmount(gasDelta), denominator)
+}
+
+// NextBaseFee is the base fee the next block on the head must carry
+func (cm *ChainManager) NextBaseFee() Amount {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+	return cm.nextBaseFee()
+}
+
+// SuggestFees returns a max fee and priority fee that stay valid even if
+// the base fee rises for a few blocks
+func (cm *ChainManager) SuggestFees() (maxFee, priorityFee Amount) {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+	return cm.suggestFees()
+}
+
+// nextBaseFee assumes the caller holds cm.mutex
+func (cm *ChainManager) nextBaseFee() Amount {
+	return CalcBaseFee(&cm.latestBlock().Header, cm.config.MinGasPrice)
+}
+
+// suggestFees assumes the caller holds cm.mutex
+func (cm *ChainManager) suggestFees() (Amount, Amount) {
+	priorityFee := NewAmount(DefaultPriorityFee)
+	maxFee, err := cm.nextBaseFee().MulUint64(2)
+	if err == nil {
+		maxFee, err = maxFee.Add(priorityFee)
+	}
+	if err != nil {
+		return cm.nextBaseFee(), priorityFee
+	}
+	return maxFee, priorityFee
+}
+
+// FeeCap is the most the sender pays per gas. Legacy transactions
+// without dynamic fee fields pay GasPrice; what exceeds the base fee is tip.
+func (tx *Transaction) FeeCap() Amount {
+	if tx.IsDynamicFee() {
+		return tx.MaxFeePerGas
+	}
+	return tx.GasPrice
+}
+
+// TipCap is the most the validator receives per gas
+func (tx *Transaction) TipCap() Amount {
+	if tx.IsDynamicFee() {
+		return tx.MaxPriorityFeePerGas
+	}
+	return tx.GasPrice
+}
+
+func (tx *Transaction) IsDynamicFee() bool {
+	return !tx.MaxFeePerGas.IsZero() || !tx.MaxPriorityFeePerGas.IsZero()
+}
+
+// EffectiveGasPrice is min(FeeCap, baseFee + TipCap); callers check
+// FeeCap >= baseFee first
+func (tx *Transaction) EffectiveGasPrice(baseFee Amount) Amount {
+	price, err := baseFee.Add(tx.TipCap())
+	if err != nil || price.Cmp(tx.FeeCap()) > 0 {
+		return tx.FeeCap()
+	}
+	return price
+}
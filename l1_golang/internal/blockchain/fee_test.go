@@ -0,0 +1,66 @@
+package blockchain
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCalcBaseFee(t *testing.T) {
+	tests := []struct {
+		name     string
+		gasLimit uint64
+		gasUsed  uint64
+		baseFee  uint64
+		min      uint64
+		want     uint64
+	}{
+		{"at target", 1000000, 500000, 1000, 0, 1000},
+		{"full block", 1000000, 1000000, 1000, 0, 1125},
+		{"just over target", 1000000, 500001, 8, 0, 9},
+		{"empty block", 1000000, 0, 1000, 0, 875},
+		{"just under target", 1000000, 499999, 1000, 0, 1000},
+		{"floored at the minimum", 1000000, 0, 1000, 900, 900},
+		{"raised to the minimum", 1000000, 500000, 0, 7, 7},
+		{"no target", 1, 0, 1000, 0, 1000},
+		{"full block at the largest gas limit", math.MaxUint64, math.MaxUint64, 1000, 0, 1125},
+		{"empty block at the largest gas limit", math.MaxUint64, 0, 1000, 0, 875},
+	}
+	for _, test := range tests {
+		parent := BlockHeader{GasLimit: test.gasLimit, GasUsed: test.gasUsed, BaseFee: NewAmount(test.baseFee)}
+		if got := CalcBaseFee(&parent, NewAmount(test.min)); got != NewAmount(test.want) {
+			t.Errorf("%s: base fee %s, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestFeeBurnedAndTipped(t *testing.T) {
+	c := newTestChain(t, 1)
+	c.config.MinGasPrice = NewAmount(1000000000)
+	c = c.fork(nil)
+	recipient := keyAddress(newTestKey(t))
+	validator := keyAddress(c.producer)
+
+	tx := c.transfer(0, 0, recipient, NUSA(5))
+	balance := c.cm.GetBalance(c.addrs[0])
+	block := c.mine(tx)
+	if block.Header.BaseFee.IsZero() || tx.TipCap().IsZero() {
+		t.Fatalf("base fee %s tip %s", block.Header.BaseFee, tx.TipCap())
+	}
+
+	// The sender pays base fee and tip, the validator only gets the tip
+	burned, _ := block.Header.BaseFee.MulUint64(TxGas)
+	tip, _ := tx.TipCap().MulUint64(TxGas)
+	paid, _ := burned.Add(tip)
+	want, _ := balance.Sub(NUSA(5))
+	want, _ = want.Sub(paid)
+	if got := c.cm.GetBalance(c.addrs[0]); got != want {
+		t.Errorf("sender balance %s, want %s", got, want)
+	}
+	reward, _ := c.config.BlockReward.Add(tip)
+	if got := c.cm.GetBalance(validator); got != reward {
+		t.Errorf("validator balance %s, want reward and tip %s", got, reward)
+	}
+	if supply := c.cm.GetSupply(); supply.Burned != burned {
+		t.Errorf("burned %s, want %s", supply.Burned, burned)
+	}
+}
@@ -0,0 +1,248 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Finality is decided by validator votes, Tendermint style. For a block at
+// some height and round, validators first prevote; once prevotes backed by
+// more than 2/3 of the stake agree on a block they precommit it, and
+// precommits with the same quorum form a Justification. A justified block
+// and all its ancestors are final: ChainManager never reverts them and
+// rejects branches forking off below them. Votes are weighed by the
+// bonded stake of the validator set of the block's parent state, the set
+// that scheduled its proposer. Until a validator registers nothing past
+// genesis is finalized.
+const (
+	VotePrevote   uint8 = 1
+	VotePrecommit uint8 = 2
+)
+
+var ErrDuplicateVote = errors.New("vote already counted")
+
+// Vote is one validator's prevote or precommit for a block
+type Vote struct {
+	Type      uint8  `json:"type"`
+	Height    uint64 `json:"height"`
+	Round     uint64 `json:"round"`
+	BlockHash string `json:"block_hash"`
+	Validator string `json:"validator"`
+	Signature string `json:"signature"` // hex [R || S || V] over the vote hash
+}
+
+// Hash over the vote payload, the message the validator signs
+func (v *Vote) Hash() string {
+	bytes, _ := encodeVersioned(votePayload(v))
+	hash := sha256.Sum256(bytes)
+	return hex.EncodeToString(hash[:])
+}
+
+// Justification is the certificate finalizing a block: precommits for it
+// from validators holding more than 2/3 of the stake
+type Justification struct {
+	Height     uint64 `json:"height"`
+	Round      uint64 `json:"round"`
+	BlockHash  string `json:"block_hash"`
+	Precommits []Vote `json:"precommits"`
+}
+
+// VoteSet collects the votes of one type for one height and round and
+// tallies the stake behind each block hash
+type VoteSet struct {
+	voteType uint8
+	height   uint64
+	round    uint64
+	stakes   map[common.Address]Amount
+	total    Amount
+	votes    map[common.Address]Vote
+	power    map[string]Amount // stake voting for each block hash
+}
+
+func NewVoteSet(voteType uint8, height, round uint64, validators []ValidatorInfo) *VoteSet {
+	s := &VoteSet{
+		voteType: voteType,
+		height:   height,
+		round:    round,
+		stakes:   make(map[common.Address]Amount),
+		votes:    make(map[common.Address]Vote),
+		power:    make(map[string]Amount),
+	}
+	for _, validator := range validators {
+		s.stakes[common.HexToAddress(validator.Address)] = validator.Stake
+		s.total, _ = s.total.Add(validator.Stake)
+	}
+	return s
+}
+
+// IsValidator reports whether address may vote in the set
+func (s *VoteSet) IsValidator(address string) bool {
+	_, exists := s.stakes[common.HexToAddress(address)]
+	return common.IsHexAddress(address) && exists
+}
+
+// Add checks the vote's signature and counts its validator's stake. A
+// validator voting twice for different blocks is rejected as equivocating.
+func (s *VoteSet) Add(vote Vote) error {
+	if vote.Type != s.voteType || vote.Height != s.height || vote.Round != s.round {
+		return fmt.Errorf("vote of type %d for %d/%d, expected type %d for %d/%d",
+			vote.Type, vote.Height, vote.Round, s.voteType, s.height, s.round)
+	}
+	if !s.IsValidator(vote.Validator) {
+		return fmt.Errorf("%s is not a validator of block %d", vote.Validator, s.height)
+	}
+	validator := common.HexToAddress(vote.Validator)
+	signer, err := vote.Signer()
+	if err != nil {
+		return err
+	}
+	if signer != validator {
+		return fmt.Errorf("vote of %s signed by %s", vote.Validator, signer.Hex())
+	}
+
+	if previous, voted := s.votes[validator]; voted {
+		if previous.BlockHash != vote.BlockHash {
+			return fmt.Errorf("%s voted for both %s and %s", vote.Validator, previous.BlockHash, vote.BlockHash)
+		}
+		return ErrDuplicateVote
+	}
+	s.votes[validator] = vote
+	s.power[vote.BlockHash], _ = s.power[vote.BlockHash].Add(s.stakes[validator])
+	return nil
+}
+
+// Quorum returns the block hash backed by more than 2/3 of the stake, if any
+func (s *VoteSet) Quorum() (string, bool) {
+	threshold := s.total.MulDiv(2, 3)
+	for hash, power := range s.power {
+		if power.Cmp(threshold) > 0 {
+			return hash, true
+		}
+	}
+	return "", false
+}
+
+// Votes returns the votes for hash, sorted by validator
+func (s *VoteSet) Votes(hash string) []Vote {
+	votes := []Vote{}
+	for _, vote := range s.votes {
+		if vote.BlockHash == hash {
+			votes = append(votes, vote)
+		}
+	}
+	sort.Slice(votes, func(i, j int) bool { return votes[i].Validator < votes[j].Validator })
+	return votes
+}
+
+// FinalizedBlock returns the last finalized block, genesis until the
+// first justification
+func (cm *ChainManager) FinalizedBlock() *Block {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+	return cm.finalized
+}
+
+// FinalizedHeight is the height of FinalizedBlock
+func (cm *ChainManager) FinalizedHeight() uint64 {
+	return cm.FinalizedBlock().Header.Height
+}
+
+// VoteValidators returns the validators whose votes count for the block
+// with hash: the set of its parent state
+func (cm *ChainManager) VoteValidators(hash string) ([]ValidatorInfo, error) {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	block, exists := cm.blocks[hash]
+	if !exists {
+		return nil, fmt.Errorf("unknown block %s", hash)
+	}
+	validators, err := readValidatorSet(cm.db, block.Header.PrevHash)
+	if err != nil {
+		return nil, fmt.Errorf("validator set of block %d unknown: %v", block.Header.Height, err)
+	}
+	return validators, nil
+}
+
+// GetJustification returns the certificate stored for a block
+func (cm *ChainManager) GetJustification(hash string) (*Justification, error) {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	justification, err := readJustification(cm.db, hash)
+	if err != nil {
+		return nil, fmt.Errorf("no justification for block %s", hash)
+	}
+	return justification, nil
+}
+
+// AddJustification checks a certificate and finalizes its block together
+// with every ancestor. A justified block on a side branch becomes the
+// head first, as long as the branch does not fork off below the
+// finalized block.
+func (cm *ChainManager) AddJustification(justification *Justification) error {
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
+
+	block, exists := cm.blocks[justification.BlockHash]
+	if !exists {
+		return fmt.Errorf("unknown block %s", justification.BlockHash)
+	}
+	if block.Header.Height != justification.Height {
+		return fmt.Errorf("justification for height %d names block %d", justification.Height, block.Header.Height)
+	}
+	if block.Header.Height <= cm.finalized.Header.Height {
+		return fmt.Errorf("block %d is not above finalized block %d", block.Header.Height, cm.finalized.Header.Height)
+	}
+
+	validators, err := readValidatorSet(cm.db, block.Header.PrevHash)
+	if err != nil {
+		return fmt.Errorf("validator set of block %d unknown: %v", block.Header.Height, err)
+	}
+	if err := verifyJustification(justification, validators); err != nil {
+		return fmt.Errorf("invalid justification: %v", err)
+	}
+
+	if !cm.isCanonical(block) {
+		ancestor := cm.commonAncestor(block)
+		if ancestor.Header.Height < cm.finalized.Header.Height {
+			return fmt.Errorf("justified block %s conflicts with finalized block %d", justification.BlockHash, cm.finalized.Header.Height)
+		}
+		if err := cm.reorg(block, ancestor); err != nil {
+			return err
+		}
+	}
+
+	batch := cm.db.NewBatch()
+	if err := writeJustification(batch, justification); err != nil {
+		return err
+	}
+	if err := batch.Write(); err != nil {
+		return fmt.Errorf("failed to persist justification: %v", err)
+	}
+	cm.finalized = block
+	return nil
+}
+
+// verifyJustification checks that the precommits are signed by distinct
+// validators holding more than 2/3 of the stake
+func verifyJustification(justification *Justification, validators []ValidatorInfo) error {
+	precommits := NewVoteSet(VotePrecommit, justification.Height, justification.Round, validators)
+	for _, vote := range justification.Precommits {
+		if vote.BlockHash != justification.BlockHash {
+			return fmt.Errorf("precommit of %s for block %s", vote.Validator, vote.BlockHash)
+		}
+		if err := precommits.Add(vote); err != nil {
+			return err
+		}
+	}
+	if _, ok := precommits.Quorum(); !ok {
+		return fmt.Errorf("precommits hold no 2/3 stake quorum")
+	}
+	return nil
+}
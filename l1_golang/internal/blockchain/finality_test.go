@@ -0,0 +1,146 @@
+package blockchain
+
+import (
+	"crypto/ecdsa"
+	"testing"
+)
+
+// newValidatorChain is a testChain whose accounts are validators with the
+// given stakes, in NUSA
+func newValidatorChain(t *testing.T, stakes ...uint64) *testChain {
+	c := newTestChain(t, len(stakes))
+	for i, stake := range stakes {
+		c.config.GenesisAccounts[i].Stake = NUSA(stake)
+		c.config.GenesisAccounts[i].Validator = true
+	}
+	return c.fork(nil)
+}
+
+func signVote(t *testing.T, key *ecdsa.PrivateKey, vote Vote) Vote {
+	vote.Validator = keyAddress(key)
+	if err := vote.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+	return vote
+}
+
+// precommit signs account i's precommit for block in round
+func (c *testChain) precommit(i int, block *Block, round uint64) Vote {
+	return signVote(c.t, c.keys[i], Vote{Type: VotePrecommit, Height: block.Header.Height, Round: round, BlockHash: block.Hash()})
+}
+
+func TestVerifyJustification(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 5)
+	for i := range keys {
+		keys[i] = newTestKey(t)
+	}
+	validators := func(stakes ...uint64) []ValidatorInfo {
+		var set []ValidatorInfo
+		for i, stake := range stakes {
+			set = append(set, ValidatorInfo{Address: keyAddress(keys[i]), Stake: NUSA(stake)})
+		}
+		return set
+	}
+	const hash = "5df6e0e2761359d30a8275058e299fcc0381534545f55cf43e41983f5d4c9456"
+	precommit := func(i int) Vote {
+		return signVote(t, keys[i], Vote{Type: VotePrecommit, Height: 7, Round: 1, BlockHash: hash})
+	}
+	edited := func(i int, edit func(vote *Vote)) Vote {
+		vote := Vote{Type: VotePrecommit, Height: 7, Round: 1, BlockHash: hash}
+		edit(&vote)
+		return signVote(t, keys[i], vote)
+	}
+	forged := precommit(3)
+	forged.Validator = keyAddress(keys[0])
+
+	tests := []struct {
+		name       string
+		validators []ValidatorInfo
+		precommits []Vote
+		wantErr    bool
+	}{
+		{"three of four", validators(10, 10, 10, 10), []Vote{precommit(0), precommit(1), precommit(2)}, false},
+		{"all four", validators(10, 10, 10, 10), []Vote{precommit(0), precommit(1), precommit(2), precommit(3)}, false},
+		{"two of four", validators(10, 10, 10, 10), []Vote{precommit(0), precommit(1)}, true},
+		{"exactly two thirds", validators(10, 10, 10), []Vote{precommit(0), precommit(1)}, true},
+		{"one validator with most stake", validators(70, 10, 10, 10), []Vote{precommit(0)}, false},
+		{"three small validators", validators(70, 10, 10, 10), []Vote{precommit(1), precommit(2), precommit(3)}, true},
+		{"no precommits", validators(10), nil, true},
+		{"validator counted twice", validators(10, 10, 10, 10), []Vote{precommit(0), precommit(1), precommit(1)}, true},
+		{"non-validator", validators(10, 10, 10, 10), []Vote{precommit(0), precommit(1), precommit(4)}, true},
+		{"signature by another key", validators(10, 10, 10, 10), []Vote{forged, precommit(1), precommit(2)}, true},
+		{"precommit for another block", validators(10, 10, 10, 10), []Vote{precommit(0), precommit(1), edited(2, func(vote *Vote) { vote.BlockHash = "00" })}, true},
+		{"prevote", validators(10, 10, 10, 10), []Vote{precommit(0), precommit(1), edited(2, func(vote *Vote) { vote.Type = VotePrevote })}, true},
+		{"other round", validators(10, 10, 10, 10), []Vote{precommit(0), precommit(1), edited(2, func(vote *Vote) { vote.Round = 2 })}, true},
+		{"other height", validators(10, 10, 10, 10), []Vote{precommit(0), precommit(1), edited(2, func(vote *Vote) { vote.Height = 8 })}, true},
+	}
+	for _, test := range tests {
+		justification := &Justification{Height: 7, Round: 1, BlockHash: hash, Precommits: test.precommits}
+		err := verifyJustification(justification, test.validators)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: error %v, want error %v", test.name, err, test.wantErr)
+		}
+	}
+}
+
+func TestAddJustificationFinalizes(t *testing.T) {
+	c := newValidatorChain(t, 10, 10, 10, 10)
+	b1 := c.mine()
+	b2 := c.mine()
+
+	tests := []struct {
+		name          string
+		justification *Justification
+		wantErr       bool
+		finalized     *Block
+	}{
+		{"two of four", &Justification{Height: 1, BlockHash: b1.Hash(), Precommits: []Vote{c.precommit(0, b1, 0), c.precommit(1, b1, 0)}}, true, nil},
+		{"height of another block", &Justification{Height: 2, BlockHash: b1.Hash(), Precommits: []Vote{c.precommit(0, b1, 0), c.precommit(1, b1, 0), c.precommit(2, b1, 0)}}, true, nil},
+		{"unknown block", &Justification{Height: 3, BlockHash: "00"}, true, nil},
+		{"head and its ancestors", &Justification{Height: 2, Round: 1, BlockHash: b2.Hash(), Precommits: []Vote{c.precommit(0, b2, 1), c.precommit(2, b2, 1), c.precommit(3, b2, 1)}}, false, b2},
+		{"below the finalized block", &Justification{Height: 1, BlockHash: b1.Hash(), Precommits: []Vote{c.precommit(0, b1, 0), c.precommit(1, b1, 0), c.precommit(2, b1, 0)}}, true, b2},
+	}
+	genesis := c.cm.FinalizedBlock()
+	for _, test := range tests {
+		err := c.cm.AddJustification(test.justification)
+		if (err != nil) != test.wantErr {
+			t.Fatalf("%s: error %v, want error %v", test.name, err, test.wantErr)
+		}
+		want := test.finalized
+		if want == nil {
+			want = genesis
+		}
+		if finalized := c.cm.FinalizedBlock(); finalized != want {
+			t.Fatalf("%s: finalized #%d, want #%d", test.name, finalized.Header.Height, want.Header.Height)
+		}
+	}
+	if _, err := c.cm.GetJustification(b2.Hash()); err != nil {
+		t.Fatal(err)
+	}
+
+	// Finalized blocks stay
+	if _, err := c.cm.RevertBlocks(1); err == nil {
+		t.Fatal("reverted the finalized block")
+	}
+}
+
+func TestForkBelowFinalizedRejected(t *testing.T) {
+	a := newValidatorChain(t, 10, 10, 10, 10)
+	b := a.fork(nil)
+	a1 := a.mine(a.transfer(0, 0, a.addrs[1], NUSA(1)))
+	b1 := b.mine()
+	b2 := b.mine()
+
+	justification := &Justification{Height: 1, BlockHash: a1.Hash(), Precommits: []Vote{a.precommit(0, a1, 0), a.precommit(1, a1, 0), a.precommit(2, a1, 0)}}
+	if err := a.cm.AddJustification(justification); err != nil {
+		t.Fatal(err)
+	}
+	for _, block := range []*Block{b1, b2} {
+		if err := a.cm.AddBlock(block); err == nil {
+			t.Fatalf("block #%d forking off below the finalized block added", block.Header.Height)
+		}
+	}
+	if head := a.cm.GetLatestBlock(); head != a1 {
+		t.Fatalf("head moved to #%d", head.Header.Height)
+	}
+}
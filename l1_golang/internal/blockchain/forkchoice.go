@@ -0,0 +1,33 @@
+package blockchain
+
+// ChainTip summarises one end of a branch in the block tree
+type ChainTip struct {
+	Hash            string `json:"hash"`
+	Height          uint64 `json:"height"`
+	TotalDifficulty uint64 `json:"total_difficulty"`
+}
+
+// ForkChoice decides which branch of the block tree is canonical.
+// ancestor is the last block the current head and the candidate share.
+type ForkChoice interface {
+	ShouldReorg(head, candidate, ancestor ChainTip) bool
+}
+
+// LongestChain prefers the branch with the most blocks; ties keep the head
+type LongestChain struct{}
+
+func (LongestChain) ShouldReorg(head, candidate, ancestor ChainTip) bool {
+	return candidate.Height > head.Height
+}
+
+// HeaviestChain prefers the branch with the most cumulative difficulty.
+// PoVC producers choose their blocks' difficulty and nothing validates it,
+// so ChainManager uses LongestChain unless told otherwise.
+type HeaviestChain struct{}
+
+func (HeaviestChain) ShouldReorg(head, candidate, ancestor ChainTip) bool {
+	if candidate.TotalDifficulty != head.TotalDifficulty {
+		return candidate.TotalDifficulty > head.TotalDifficulty
+	}
+	return candidate.Height > head.Height
+}
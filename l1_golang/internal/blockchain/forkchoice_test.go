@@ -0,0 +1,189 @@
+package blockchain
+
+import (
+	"crypto/ecdsa"
+	"strings"
+	"testing"
+)
+
+func TestForkChoiceRules(t *testing.T) {
+	ancestor := ChainTip{Height: 1, TotalDifficulty: 10}
+	tests := []struct {
+		name      string
+		rule      ForkChoice
+		head      ChainTip
+		candidate ChainTip
+		want      bool
+	}{
+		{"longest: longer candidate", LongestChain{}, ChainTip{Height: 3, TotalDifficulty: 90}, ChainTip{Height: 4, TotalDifficulty: 20}, true},
+		{"longest: equal height keeps head", LongestChain{}, ChainTip{Height: 3}, ChainTip{Height: 3}, false},
+		{"longest: shorter candidate", LongestChain{}, ChainTip{Height: 3}, ChainTip{Height: 2, TotalDifficulty: 90}, false},
+		{"heaviest: heavier candidate", HeaviestChain{}, ChainTip{Height: 3, TotalDifficulty: 30}, ChainTip{Height: 2, TotalDifficulty: 40}, true},
+		{"heaviest: lighter longer candidate", HeaviestChain{}, ChainTip{Height: 3, TotalDifficulty: 30}, ChainTip{Height: 4, TotalDifficulty: 20}, false},
+		{"heaviest: equal weight prefers longer", HeaviestChain{}, ChainTip{Height: 3, TotalDifficulty: 30}, ChainTip{Height: 4, TotalDifficulty: 30}, true},
+		{"heaviest: full tie keeps head", HeaviestChain{}, ChainTip{Height: 3, TotalDifficulty: 30}, ChainTip{Height: 3, TotalDifficulty: 30}, false},
+	}
+	for _, test := range tests {
+		if got := test.rule.ShouldReorg(test.head, test.candidate, ancestor); got != test.want {
+			t.Errorf("%s: reorg %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestLongerBranchReorgs(t *testing.T) {
+	a := newTestChain(t, 2)
+	b := a.fork(nil)
+	tx := a.transfer(0, 0, a.addrs[1], NUSA(5))
+	a1 := a.mine(tx)
+	b1 := b.mine()
+	b2 := b.mine()
+
+	// A branch as long as the head does not replace it
+	if err := a.cm.AddBlock(b1); err != nil {
+		t.Fatal(err)
+	}
+	if head := a.cm.GetLatestBlock(); head != a1 {
+		t.Fatalf("head moved to #%d on a tie", head.Header.Height)
+	}
+
+	if err := a.cm.AddBlock(b2); err != nil {
+		t.Fatal(err)
+	}
+	if head := a.cm.GetLatestBlock(); head.Hash() != b2.Hash() {
+		t.Fatalf("head is %s, want b2 %s", head.Hash(), b2.Hash())
+	}
+	a.requireState(b.state())
+
+	// The transfer a1 carried is pending again
+	pending := a.cm.GetPendingTXs()
+	if len(pending) != 1 || pending[0].Hash != tx.Hash {
+		t.Fatalf("pending %v, want the orphaned transfer", pending)
+	}
+	if _, _, err := a.cm.GetTransaction(tx.Hash); err == nil {
+		t.Fatal("orphaned transfer still indexed")
+	}
+
+	// a1 is kept, so extending it can take the head back
+	a1Child := NewBlock(2, a1.Hash(), nil, keyAddress(a.producer))
+	a1Child.Header.Timestamp = a1.Header.Timestamp + 1
+	if err := a1Child.Sign(a.producer); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.cm.AddBlock(a1Child); err != nil {
+		t.Fatal(err)
+	}
+	if head := a.cm.GetLatestBlock(); head != b2 {
+		t.Fatal("an equally long branch took the head")
+	}
+}
+
+func TestSideBlocksCheckedBeforeStoring(t *testing.T) {
+	a := newTestChain(t, 1)
+	a.mine()
+	b := a.fork(nil)
+	genesis, err := a.cm.GetBlockByHeight(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Each block branches off genesis, beside the head
+	sideBlock := func(signer *ecdsa.PrivateKey, edit func(block *Block)) *Block {
+		block := NewBlock(1, genesis.Hash(), nil, keyAddress(b.producer))
+		block.Header.Timestamp = genesis.Header.Timestamp + 1
+		edit(block)
+		if signer != nil {
+			if err := block.Sign(signer); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return block
+	}
+	tests := []struct {
+		name    string
+		block   *Block
+		wantErr bool
+	}{
+		{"signed by its validator", sideBlock(b.producer, func(*Block) {}), false},
+		{"unsigned", sideBlock(nil, func(block *Block) { block.Header.Timestamp++ }), true},
+		{"signature by another key", func() *Block {
+			block := sideBlock(b.producer, func(*Block) {})
+			block.Header.Validator = keyAddress(a.producer)
+			return block
+		}(), true},
+		{"wrong height", sideBlock(b.producer, func(block *Block) { block.Header.Height = 2 }), true},
+		{"timestamp not after parent", sideBlock(b.producer, func(block *Block) { block.Header.Timestamp = genesis.Header.Timestamp }), true},
+		{"lowercase validator", sideBlock(b.producer, func(block *Block) { block.Header.Validator = strings.ToLower(block.Header.Validator) }), true},
+	}
+	for _, test := range tests {
+		err := a.cm.AddBlock(test.block)
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: error %v, want error %v", test.name, err, test.wantErr)
+		}
+		_, stored := a.cm.blocks[test.block.Hash()]
+		if stored == test.wantErr {
+			t.Errorf("%s: stored %v", test.name, stored)
+		}
+	}
+}
+
+func TestSideBlockProposerUnreadable(t *testing.T) {
+	a := newTestChain(t, 1)
+	b := a.fork(nil)
+	a.mine()
+	genesis, err := a.cm.GetBlockByHeight(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A validator set that cannot be read must not leave the proposer open
+	if err := a.cm.db.Put(validatorSetKey(genesis.Hash()), []byte("{")); err != nil {
+		t.Fatal(err)
+	}
+	b1 := b.mine()
+	if err := a.cm.AddBlock(b1); err == nil || !strings.Contains(err.Error(), "corrupt validator set") {
+		t.Errorf("side block over an unreadable validator set: error %v", err)
+	}
+	if _, stored := a.cm.blocks[b1.Hash()]; stored {
+		t.Error("side block stored")
+	}
+}
+
+func TestFailedReorgRestoresHead(t *testing.T) {
+	a := newTestChain(t, 2)
+	b := a.fork(nil)
+	a.mine(a.transfer(0, 0, a.addrs[1], NUSA(5)))
+	a.mine()
+	before := a.state()
+
+	// b2 claims a state its block does not produce; b3 on top of it makes
+	// the branch the longest
+	b1 := b.mine()
+	b2 := b.build()
+	b2.Header.StateRoot = b1.Header.StateRoot
+	if err := b2.Sign(b.producer); err != nil {
+		t.Fatal(err)
+	}
+	b3 := NewBlock(3, b2.Hash(), nil, keyAddress(b.producer))
+	b3.Header.Timestamp = b2.Header.Timestamp + 1
+	if err := b3.Sign(b.producer); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, block := range []*Block{b1, b2} {
+		if err := a.cm.AddBlock(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.cm.AddBlock(b3); err == nil {
+		t.Fatal("reorg onto an invalid block succeeded")
+	}
+	a.requireState(before)
+	for _, block := range []*Block{b2, b3} {
+		if _, kept := a.cm.blocks[block.Hash()]; kept {
+			t.Errorf("invalid block #%d kept", block.Header.Height)
+		}
+	}
+	if _, kept := a.cm.blocks[b1.Hash()]; !kept {
+		t.Error("valid block b1 dropped")
+	}
+}
@@ -0,0 +1,53 @@
+package blockchain
+
+import "fmt"
+
+// Gas schedule. There is no VM yet, so a transaction uses exactly its
+// intrinsic gas and the rest of its limit is refunded.
+const (
+	TxGas            uint64 = 21000 // base cost of every transaction
+	TxDataZeroGas    uint64 = 4     // per zero byte of Data
+	TxDataNonZeroGas uint64 = 16    // per non-zero byte of Data
+)
+
+// IntrinsicGas is the gas a transaction pays before doing anything
+func IntrinsicGas(data []byte) uint64 {
+	gas := TxGas
+	for _, b := range data {
+		if b == 0 {
+			gas += TxDataZeroGas
+		} else {
+			gas += TxDataNonZeroGas
+		}
+	}
+	return gas
+}
+
+// Check the pricing rules every included transaction has to meet
+func (cm *ChainManager) checkGas(tx *Transaction) error {
+	if intrinsic := IntrinsicGas(tx.Data); tx.GasLimit < intrinsic {
+		return fmt.Errorf("gas limit %d below intrinsic gas %d", tx.GasLimit, intrinsic)
+	}
+	if tx.FeeCap().Cmp(cm.config.MinGasPrice) < 0 {
+		return fmt.Errorf("fee cap %s below minimum %s", tx.FeeCap(), cm.config.MinGasPrice)
+	}
+	if tx.TipCap().Cmp(tx.FeeCap()) > 0 {
+		return fmt.Errorf("priority fee %s above fee cap %s", tx.TipCap(), tx.FeeCap())
+	}
+	return nil
+}
+
+// Check a block's gas and base fee fields against its parent and the gas
+// its transactions used
+func (cm *ChainManager) validateBlockGas(block, parent *Block, gasUsed uint64) error {
+	if baseFee := CalcBaseFee(&parent.Header, cm.config.MinGasPrice); block.Header.BaseFee != baseFee {
+		return fmt.Errorf("invalid base fee: header %s, expected %s", block.Header.BaseFee, baseFee)
+	}
+	if cm.config.MaxGasLimit > 0 && block.Header.GasLimit > cm.config.MaxGasLimit {
+		return fmt.Errorf("block gas limit %d above maximum %d", block.Header.GasLimit, cm.config.MaxGasLimit)
+	}
+	if block.Header.GasUsed != gasUsed {
+		return fmt.Errorf("invalid gas used: header %d, executed %d", block.Header.GasUsed, gasUsed)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package blockchain
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIntrinsicGas(t *testing.T) {
+	tests := []struct {
+		data []byte
+		want uint64
+	}{
+		{nil, TxGas},
+		{[]byte{0}, TxGas + TxDataZeroGas},
+		{[]byte{1}, TxGas + TxDataNonZeroGas},
+		{[]byte("memo\x00\x00"), TxGas + 4*TxDataNonZeroGas + 2*TxDataZeroGas},
+	}
+	for _, test := range tests {
+		if got := IntrinsicGas(test.data); got != test.want {
+			t.Errorf("%x: %d, want %d", test.data, got, test.want)
+		}
+	}
+
+	c := newTestChain(t, 1)
+	tx := c.transfer(0, 0, c.addrs[0], NUSA(1))
+	tx.Data = []byte("memo")
+	if err := tx.Sign(c.keys[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.cm.AddTransaction(tx); err == nil || !strings.Contains(err.Error(), "intrinsic gas") {
+		t.Errorf("memo within TxGas: error %v", err)
+	}
+}
+
+func TestGasRefund(t *testing.T) {
+	c := newTestChain(t, 1)
+	c.config.MinGasPrice = NewAmount(1000000000)
+	c = c.fork(nil)
+	recipient := keyAddress(newTestKey(t))
+
+	// Only the intrinsic gas is charged out of a generous limit
+	tx := c.transfer(0, 0, recipient, NUSA(5))
+	tx.Data = []byte("memo")
+	tx.GasLimit = 100000
+	if err := tx.Sign(c.keys[0]); err != nil {
+		t.Fatal(err)
+	}
+	balance := c.cm.GetBalance(c.addrs[0])
+	block := c.mine(tx)
+
+	used := IntrinsicGas(tx.Data)
+	receipt, err := c.cm.GetReceipt(tx.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if receipt.GasUsed != used || block.Header.GasUsed != used {
+		t.Errorf("gas used %d, header %d, want %d", receipt.GasUsed, block.Header.GasUsed, used)
+	}
+	fee, _ := tx.EffectiveGasPrice(block.Header.BaseFee).MulUint64(used)
+	want, _ := balance.Sub(NUSA(5))
+	want, _ = want.Sub(fee)
+	if got := c.cm.GetBalance(c.addrs[0]); got != want || receipt.Fee != fee {
+		t.Errorf("balance %s fee %s, want %s %s", got, receipt.Fee, want, fee)
+	}
+}
+
+func TestBlockGasLimit(t *testing.T) {
+	c := newTestChain(t, 2)
+	head := c.cm.GetLatestBlock()
+	var txs []Transaction
+	for i := range c.keys {
+		tx := c.transfer(i, 0, c.addrs[1-i], NUSA(1))
+		tx.GasLimit = 100000
+		if err := tx.Sign(c.keys[i]); err != nil {
+			t.Fatal(err)
+		}
+		txs = append(txs, tx)
+	}
+
+	// The second transaction's limit on top of the gas the first used does
+	// not fit, though the gas both use would
+	block := c.build(txs...)
+	block.Header.GasLimit = TxGas + 100000 - 1
+	if err := block.Sign(c.producer); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.cm.AddBlock(block); err == nil || !strings.Contains(err.Error(), "exceeds block gas limit") {
+		t.Errorf("block over its gas limit: error %v", err)
+	}
+	if c.cm.GetLatestBlock() != head {
+		t.Fatal("head moved")
+	}
+
+	block = c.build(txs...)
+	block.Header.GasLimit = TxGas + 100000
+	if err := block.Sign(c.producer); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.cm.AddBlock(block); err != nil {
+		t.Fatalf("block at its gas limit: %v", err)
+	}
+}
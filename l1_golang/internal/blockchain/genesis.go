@@ -0,0 +1,186 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+// Genesis mirrors config/genesis.json. Numbers may be decimal or 0x hex.
+type Genesis struct {
+	Config     GenesisConfig           `json:"config"`
+	Alloc      map[string]GenesisAlloc `json:"alloc"`
+	Coinbase   string                  `json:"coinbase"`
+	Difficulty math.HexOrDecimal64     `json:"difficulty"`
+	ExtraData  string                  `json:"extraData"`
+	GasLimit   math.HexOrDecimal64     `json:"gasLimit"`
+	Nonce      math.HexOrDecimal64     `json:"nonce"`
+	Mixhash    string                  `json:"mixhash"`
+	ParentHash string                  `json:"parentHash"`
+	Timestamp  math.HexOrDecimal64     `json:"timestamp"`
+}
+
+// GenesisConfig is the "config" section. The Ethereum fork blocks are
+// accepted for compatibility; only povcBlock changes behaviour here.
+type GenesisConfig struct {
+	ChainID             uint64  `json:"chainId"`
+	HomesteadBlock      *uint64 `json:"homesteadBlock,omitempty"`
+	EIP150Block         *uint64 `json:"eip150Block,omitempty"`
+	EIP155Block         *uint64 `json:"eip155Block,omitempty"`
+	EIP158Block         *uint64 `json:"eip158Block,omitempty"`
+	ByzantiumBlock      *uint64 `json:"byzantiumBlock,omitempty"`
+	ConstantinopleBlock *uint64 `json:"constantinopleBlock,omitempty"`
+	PetersburgBlock     *uint64 `json:"petersburgBlock,omitempty"`
+	IstanbulBlock       *uint64 `json:"istanbulBlock,omitempty"`
+	BerlinBlock         *uint64 `json:"berlinBlock,omitempty"`
+	LondonBlock         *uint64 `json:"londonBlock,omitempty"`
+	PoVCBlock           *uint64 `json:"povcBlock,omitempty"`
+
+	// Optional chain parameters; defaults match the original testnet
+	BlockTime   uint64  `json:"blockTime,omitempty"`
+	MaxGasLimit uint64  `json:"maxGasLimit,omitempty"` // for blocks after genesis
+	MinGasPrice *Amount `json:"minGasPrice,omitempty"`
+	BlockReward *Amount `json:"blockReward,omitempty"`
+
+	UnbondingPeriod uint64 `json:"unbondingPeriod,omitempty"` // in blocks
+	NVSOracle       string `json:"nvsOracle,omitempty"`
+}
+
+type GenesisAlloc struct {
+	Balance   Amount  `json:"balance"`
+	Stake     *Amount `json:"stake,omitempty"`
+	Validator bool    `json:"validator,omitempty"` // needs stake
+}
+
+// GenesisHeader holds the block 0 header fields that come from genesis.json.
+// GasLimit is block 0's own; later blocks are capped by MaxGasLimit.
+type GenesisHeader struct {
+	Timestamp  int64  `json:"timestamp"`
+	Coinbase   string `json:"coinbase,omitempty"`
+	Nonce      uint64 `json:"nonce"`
+	Difficulty uint64 `json:"difficulty"`
+	GasLimit   uint64 `json:"gas_limit"`
+	ExtraData  string `json:"extra_data,omitempty"`
+}
+
+const (
+	defaultBlockTime   = 5
+	defaultMaxGasLimit = 8000000
+
+	defaultUnbondingPeriod = 17280 // one day of 5 second blocks
+)
+
+// LoadGenesis reads and parses a genesis file
+func LoadGenesis(path string) (*Genesis, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read genesis: %v", err)
+	}
+
+	var genesis Genesis
+	if err := json.Unmarshal(data, &genesis); err != nil {
+		return nil, fmt.Errorf("invalid genesis %s: %v", path, err)
+	}
+	if genesis.Config.ChainID == 0 {
+		return nil, fmt.Errorf("invalid genesis %s: missing chainId", path)
+	}
+	for address, alloc := range genesis.Alloc {
+		if alloc.Validator && (alloc.Stake == nil || alloc.Stake.IsZero()) {
+			return nil, fmt.Errorf("invalid genesis %s: validator %s has no stake", path, address)
+		}
+	}
+	if _, err := genesis.ChainConfig(); err != nil {
+		return nil, fmt.Errorf("invalid genesis %s: %v", path, err)
+	}
+	return &genesis, nil
+}
+
+// ChainConfig converts the genesis into the config NewChainManager takes.
+// Accounts are sorted by canonical address so every node builds the same
+// block 0 whatever spelling its genesis file uses.
+func (g *Genesis) ChainConfig() (ChainConfig, error) {
+	config := ChainConfig{
+		ChainID:         g.Config.ChainID,
+		BlockTime:       g.Config.BlockTime,
+		Difficulty:      uint64(g.Difficulty),
+		MaxGasLimit:     g.Config.MaxGasLimit,
+		MinGasPrice:     NewAmount(1000000000), // 1 gwei
+		BlockReward:     NUSA(2),
+		UnbondingPeriod: g.Config.UnbondingPeriod,
+		NVSOracle:       g.Config.NVSOracle,
+		Genesis: GenesisHeader{
+			Timestamp:  int64(g.Timestamp),
+			Coinbase:   g.Coinbase,
+			Nonce:      uint64(g.Nonce),
+			Difficulty: uint64(g.Difficulty),
+			GasLimit:   uint64(g.GasLimit),
+			ExtraData:  g.ExtraData,
+		},
+	}
+	if config.BlockTime == 0 {
+		config.BlockTime = defaultBlockTime
+	}
+	if config.MaxGasLimit == 0 {
+		config.MaxGasLimit = defaultMaxGasLimit
+	}
+	if config.UnbondingPeriod == 0 {
+		config.UnbondingPeriod = defaultUnbondingPeriod
+	}
+	if g.Config.MinGasPrice != nil {
+		config.MinGasPrice = *g.Config.MinGasPrice
+	}
+	if g.Config.BlockReward != nil {
+		config.BlockReward = *g.Config.BlockReward
+	}
+	if g.Config.PoVCBlock != nil {
+		config.PoVCBlock = *g.Config.PoVCBlock
+	}
+
+	// Spellings of one address would land in the same account
+	allocs := make(map[string]GenesisAlloc, len(g.Alloc))
+	addresses := make([]string, 0, len(g.Alloc))
+	for address, alloc := range g.Alloc {
+		canonical := CanonicalAddress(address)
+		if _, exists := allocs[canonical]; exists {
+			return ChainConfig{}, fmt.Errorf("%s allocated twice", canonical)
+		}
+		allocs[canonical] = alloc
+		addresses = append(addresses, canonical)
+	}
+	sort.Strings(addresses)
+	for _, address := range addresses {
+		alloc := allocs[address]
+		account := GenesisAccount{Address: address, Balance: alloc.Balance, Validator: alloc.Validator}
+		if alloc.Stake != nil {
+			account.Stake = *alloc.Stake
+		}
+		config.GenesisAccounts = append(config.GenesisAccounts, account)
+	}
+
+	return config, nil
+}
+
+// Hash of block 0, for comparing genesis files between nodes
+func (g *Genesis) Hash() (string, error) {
+	config, err := g.ChainConfig()
+	if err != nil {
+		return "", err
+	}
+	block, err := GenesisBlock(config)
+	if err != nil {
+		return "", err
+	}
+	return block.Hash(), nil
+}
+
+// GenesisBlock builds block 0 for config in memory, without touching a datadir
+func GenesisBlock(config ChainConfig) (*Block, error) {
+	cm, err := NewChainManager(config, nil)
+	if err != nil {
+		return nil, err
+	}
+	return cm.Chain[0], nil
+}
@@ -0,0 +1,99 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// The genesis the node ships with must be able to grow a chain with the
+// blocks NewBlock builds
+func TestShippedGenesisProducesBlock(t *testing.T) {
+	genesis, err := LoadGenesis("../../../config/genesis.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := genesis.ChainConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cm, err := NewChainManager(config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	head := cm.GetLatestBlock()
+	block := NewBlock(head.Header.Height+1, head.Hash(), nil, crypto.PubkeyToAddress(key.PublicKey).Hex())
+	if err := cm.SealStateRoot(block); err != nil {
+		t.Fatal(err)
+	}
+	if err := block.Sign(key); err != nil {
+		t.Fatal(err)
+	}
+	if err := cm.AddBlock(block); err != nil {
+		t.Fatalf("block #1 on the shipped genesis: %v", err)
+	}
+	if got := cm.GetLatestBlock().Hash(); got != block.Hash() {
+		t.Fatalf("head is %s, want %s", got, block.Hash())
+	}
+}
+
+func TestGenesisAllocSpellings(t *testing.T) {
+	// Checksummed spellings sort differently from lowercase ones
+	var canonical []string
+	for _, hash := range testHashes(8) {
+		canonical = append(canonical, CanonicalAddress("0x"+hash[:40]))
+	}
+	configs := make([]ChainConfig, 2)
+	for i, spell := range []func(string) string{strings.ToLower, func(address string) string { return address }} {
+		genesis := Genesis{Config: GenesisConfig{ChainID: 1}, Alloc: make(map[string]GenesisAlloc)}
+		for j, address := range canonical {
+			genesis.Alloc[spell(address)] = GenesisAlloc{Balance: NUSA(uint64(j + 1))}
+		}
+		config, err := genesis.ChainConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		configs[i] = config
+	}
+	if !reflect.DeepEqual(configs[0].GenesisAccounts, configs[1].GenesisAccounts) {
+		t.Errorf("spelling changes the genesis accounts:\n%+v\n%+v", configs[0].GenesisAccounts, configs[1].GenesisAccounts)
+	}
+	for i, account := range configs[0].GenesisAccounts {
+		if i > 0 && account.Address <= configs[0].GenesisAccounts[i-1].Address {
+			t.Fatalf("accounts out of order at %d: %+v", i, configs[0].GenesisAccounts)
+		}
+		if !isCanonicalAddress(account.Address) {
+			t.Errorf("account %s not canonical", account.Address)
+		}
+	}
+
+	// Two spellings of one address are one account allocated twice
+	genesis := Genesis{Config: GenesisConfig{ChainID: 1}, Alloc: map[string]GenesisAlloc{
+		canonical[0]:                  {Balance: NUSA(1)},
+		strings.ToLower(canonical[0]): {Balance: NUSA(2)},
+	}}
+	if _, err := genesis.ChainConfig(); err == nil || !strings.Contains(err.Error(), "allocated twice") {
+		t.Errorf("duplicate alloc: error %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "genesis.json")
+	data, err := json.Marshal(genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadGenesis(path); err == nil {
+		t.Error("genesis file with a duplicate alloc loaded")
+	}
+}
@@ -0,0 +1,176 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// Merkle trees are built over the raw bytes of hex-encoded sha256 hashes.
+// Leaves and interior nodes are hashed under different prefixes, and an odd
+// node at the end of a level moves up unchanged instead of being paired with
+// itself, so two different leaf lists never share a root.
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// MerkleProof is the branch from one leaf to the root. Siblings are listed
+// bottom up; levels where the node is promoted have no sibling.
+type MerkleProof struct {
+	Leaf      string   `json:"leaf"`
+	Index     uint64   `json:"index"`
+	LeafCount uint64   `json:"leaf_count"`
+	Siblings  []string `json:"siblings"`
+}
+
+// TxProof proves a transaction is included in a block
+type TxProof struct {
+	BlockHash   string      `json:"block_hash"`
+	BlockHeight uint64      `json:"block_height"`
+	MerkleRoot  string      `json:"merkle_root"`
+	Proof       MerkleProof `json:"proof"`
+}
+
+// GetTransactionProof returns the merkle branch of txHash in the block with
+// blockHash. An empty blockHash selects the canonical block holding the tx.
+func (cm *ChainManager) GetTransactionProof(blockHash, txHash string) (*TxProof, error) {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	if blockHash == "" {
+		lookup, err := cm.txLookup(txHash)
+		if err != nil {
+			return nil, err
+		}
+		blockHash = lookup.BlockHash
+	}
+	block, exists := cm.blocks[blockHash]
+	if !exists {
+		return nil, fmt.Errorf("block %s not found", blockHash)
+	}
+
+	hashes := block.txHashes()
+	for i, hash := range hashes {
+		if hash == txHash {
+			return &TxProof{
+				BlockHash:   blockHash,
+				BlockHeight: block.Header.Height,
+				MerkleRoot:  block.Header.MerkleRoot,
+				Proof:       buildMerkleProof(hashes, i),
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("transaction %s not found in block %s", txHash, blockHash)
+}
+
+// VerifyMerkleProof checks proof against a merkle root such as
+// BlockHeader.MerkleRoot. It needs nothing but the proof and the root.
+//
+// Only membership of Leaf is proven. The root does not commit to the leaf
+// count, so Index and LeafCount are not authenticated: the last leaf of
+// an odd level is promoted, and its branch also verifies at the position
+// its parent would have in a smaller tree. Take a transaction's position
+// from the block, not from a proof.
+func VerifyMerkleProof(proof *MerkleProof, root string) bool {
+	if proof.Index >= proof.LeafCount {
+		return false
+	}
+
+	node := merkleLeaf(proof.Leaf)
+	siblings := proof.Siblings
+	for i, n := proof.Index, proof.LeafCount; n > 1; i, n = i/2, (n+1)/2 {
+		if i^1 >= n {
+			continue // promoted
+		}
+		if len(siblings) == 0 {
+			return false
+		}
+		sibling, err := hex.DecodeString(siblings[0])
+		if err != nil || len(sibling) != sha256.Size {
+			return false
+		}
+		siblings = siblings[1:]
+
+		if i%2 == 0 {
+			node = merkleNode(node, sibling)
+		} else {
+			node = merkleNode(sibling, node)
+		}
+	}
+	return len(siblings) == 0 && hex.EncodeToString(node) == root
+}
+
+func (b *Block) txHashes() []string {
+	hashes := make([]string, len(b.Transactions))
+	for i, tx := range b.Transactions {
+		hashes[i] = tx.Hash
+	}
+	return hashes
+}
+
+// buildMerkleTree returns the root over hashes; callers handle the empty list
+func buildMerkleTree(hashes []string) string {
+	level := merkleLeaves(hashes)
+	for len(level) > 1 {
+		level = merkleParents(level)
+	}
+	return hex.EncodeToString(level[0])
+}
+
+func buildMerkleProof(hashes []string, index int) MerkleProof {
+	proof := MerkleProof{
+		Leaf:      hashes[index],
+		Index:     uint64(index),
+		LeafCount: uint64(len(hashes)),
+		Siblings:  []string{},
+	}
+
+	level := merkleLeaves(hashes)
+	for i := index; len(level) > 1; i /= 2 {
+		if sibling := i ^ 1; sibling < len(level) {
+			proof.Siblings = append(proof.Siblings, hex.EncodeToString(level[sibling]))
+		}
+		level = merkleParents(level)
+	}
+	return proof
+}
+
+func merkleLeaves(hashes []string) [][]byte {
+	leaves := make([][]byte, len(hashes))
+	for i, hash := range hashes {
+		leaves[i] = merkleLeaf(hash)
+	}
+	return leaves
+}
+
+// Pair up one level; an odd last node moves up unchanged
+func merkleParents(level [][]byte) [][]byte {
+	parents := make([][]byte, 0, (len(level)+1)/2)
+	for i := 0; i < len(level); i += 2 {
+		if i+1 < len(level) {
+			parents = append(parents, merkleNode(level[i], level[i+1]))
+		} else {
+			parents = append(parents, level[i])
+		}
+	}
+	return parents
+}
+
+func merkleLeaf(hash string) []byte {
+	raw, err := hex.DecodeString(hash)
+	if err != nil {
+		raw = []byte(hash)
+	}
+	sum := sha256.Sum256(append([]byte{merkleLeafPrefix}, raw...))
+	return sum[:]
+}
+
+func merkleNode(left, right []byte) []byte {
+	data := make([]byte, 0, 1+len(left)+len(right))
+	data = append(data, merkleNodePrefix)
+	data = append(data, left...)
+	data = append(data, right...)
+	sum := sha256.Sum256(data)
+	return sum[:]
+}
@@ -0,0 +1,147 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+func testHashes(n int) []string {
+	hashes := make([]string, n)
+	for i := range hashes {
+		sum := sha256.Sum256([]byte(fmt.Sprint(i)))
+		hashes[i] = hex.EncodeToString(sum[:])
+	}
+	return hashes
+}
+
+func TestMerkleTreeShape(t *testing.T) {
+	hashes := testHashes(3)
+	l0, l1, l2 := merkleLeaf(hashes[0]), merkleLeaf(hashes[1]), merkleLeaf(hashes[2])
+	tests := []struct {
+		name   string
+		hashes []string
+		want   []byte
+	}{
+		{"one leaf", hashes[:1], l0},
+		{"two leaves", hashes[:2], merkleNode(l0, l1)},
+		{"odd leaf promoted", hashes, merkleNode(merkleNode(l0, l1), l2)},
+	}
+	for _, test := range tests {
+		if root := buildMerkleTree(test.hashes); root != hex.EncodeToString(test.want) {
+			t.Errorf("%s: root %s, want %x", test.name, root, test.want)
+		}
+	}
+
+	// A leaf is never mistaken for the node above it
+	if buildMerkleTree(hashes[:2]) == buildMerkleTree([]string{hex.EncodeToString(merkleNode(l0, l1))}) {
+		t.Error("two leaves and their parent as a leaf share a root")
+	}
+}
+
+func TestMerkleProofs(t *testing.T) {
+	tampered := []struct {
+		name string
+		edit func(proof *MerkleProof, root string) (*MerkleProof, string)
+	}{
+		{"other leaf", func(proof *MerkleProof, root string) (*MerkleProof, string) {
+			proof.Leaf = testHashes(20)[19]
+			return proof, root
+		}},
+		{"other root", func(proof *MerkleProof, root string) (*MerkleProof, string) {
+			return proof, testHashes(20)[19]
+		}},
+		{"index out of range", func(proof *MerkleProof, root string) (*MerkleProof, string) {
+			proof.Index = proof.LeafCount
+			return proof, root
+		}},
+		{"extra sibling", func(proof *MerkleProof, root string) (*MerkleProof, string) {
+			proof.Siblings = append(proof.Siblings, testHashes(1)[0])
+			return proof, root
+		}},
+		{"sibling not a hash", func(proof *MerkleProof, root string) (*MerkleProof, string) {
+			proof.Siblings = append([]string{"zz"}, proof.Siblings...)
+			return proof, root
+		}},
+	}
+
+	for count := 1; count <= 9; count++ {
+		hashes := testHashes(count)
+		root := buildMerkleTree(hashes)
+		for index := range hashes {
+			proof := buildMerkleProof(hashes, index)
+			if !VerifyMerkleProof(&proof, root) {
+				t.Fatalf("leaf %d of %d: proof rejected", index, count)
+			}
+			for _, tamper := range tampered {
+				proof := buildMerkleProof(hashes, index)
+				edited, editedRoot := tamper.edit(&proof, root)
+				if VerifyMerkleProof(edited, editedRoot) {
+					t.Errorf("leaf %d of %d: proof with %s accepted", index, count, tamper.name)
+				}
+			}
+
+			// The branch only fits the position it was built for
+			if count > 1 {
+				proof := buildMerkleProof(hashes, index)
+				proof.Index = uint64((index + 1) % count)
+				if VerifyMerkleProof(&proof, root) {
+					t.Errorf("leaf %d of %d: proof accepted at index %d", index, count, proof.Index)
+				}
+			}
+		}
+	}
+}
+
+func TestMerkleProofPosition(t *testing.T) {
+	hashes := testHashes(3)
+	root := buildMerkleTree(hashes)
+
+	// Leaf 2 of 3 is promoted, so its branch is the same as that of index
+	// 1 in a tree of two: the position is not authenticated
+	proof := buildMerkleProof(hashes, 2)
+	proof.Index, proof.LeafCount = 1, 2
+	if !VerifyMerkleProof(&proof, root) {
+		t.Error("promoted leaf rejected at its parent's position")
+	}
+
+	// Membership still is
+	proof.Leaf = testHashes(4)[3]
+	if VerifyMerkleProof(&proof, root) {
+		t.Error("leaf outside the tree accepted")
+	}
+}
+
+func TestGetTransactionProof(t *testing.T) {
+	c := newTestChain(t, 1)
+	recipient := keyAddress(newTestKey(t))
+	var txs []Transaction
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		txs = append(txs, c.transfer(0, nonce, recipient, NUSA(1)))
+	}
+	block := c.mine(txs...)
+
+	for _, tx := range txs {
+		for _, blockHash := range []string{"", block.Hash()} {
+			proof, err := c.cm.GetTransactionProof(blockHash, tx.Hash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if proof.BlockHash != block.Hash() || proof.MerkleRoot != block.Header.MerkleRoot || proof.Proof.Leaf != tx.Hash {
+				t.Fatalf("proof %+v for block %s", proof, block.Hash())
+			}
+			if !VerifyMerkleProof(&proof.Proof, block.Header.MerkleRoot) {
+				t.Fatalf("proof of %s rejected", tx.Hash)
+			}
+		}
+	}
+
+	genesis, _ := c.cm.GetBlockByHeight(0)
+	if _, err := c.cm.GetTransactionProof(genesis.Hash(), txs[0].Hash); err == nil {
+		t.Error("proof from a block without the transaction")
+	}
+	if _, err := c.cm.GetTransactionProof("", testHashes(1)[0]); err == nil {
+		t.Error("proof of an unknown transaction")
+	}
+}
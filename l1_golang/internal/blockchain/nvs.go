@@ -0,0 +1,97 @@
+package blockchain
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// NVS scores weight validators in the proposer schedule. The AI Engine
+// computes them off chain and the oracle account named in
+// ChainConfig.NVSOracle posts them with TxTypeNVSUpdate, so every node
+// weighs validators the same. Scores are basis points from 1 to
+// MaxNVSScore; an account without one counts as DefaultNVSScore.
+const (
+	MaxNVSScore     = 10000
+	DefaultNVSScore = 5000
+)
+
+// NVSUpdate sets one account's score; the Data of a TxTypeNVSUpdate
+// transaction is an RLP list of them
+type NVSUpdate struct {
+	Address string
+	Score   uint16
+}
+
+func EncodeNVSUpdates(updates []NVSUpdate) []byte {
+	data, _ := rlp.EncodeToBytes(updates)
+	return data
+}
+
+func DecodeNVSUpdates(data []byte) ([]NVSUpdate, error) {
+	var updates []NVSUpdate
+	if err := rlp.DecodeBytes(data, &updates); err != nil {
+		return nil, fmt.Errorf("invalid NVS updates: %v", err)
+	}
+	return updates, nil
+}
+
+// NVSOracle returns the account allowed to post NVS scores, if any
+func (cm *ChainManager) NVSOracle() string {
+	return cm.config.NVSOracle
+}
+
+// Score used for weighting; zero means none was posted
+func nvsScore(account AccountState) uint16 {
+	if account.NVSScore == 0 {
+		return DefaultNVSScore
+	}
+	return account.NVSScore
+}
+
+func validateNVSUpdate(tx *Transaction) error {
+	if tx.To != "" || !tx.Value.IsZero() {
+		return fmt.Errorf("NVS updates take no recipient or value")
+	}
+	updates, err := DecodeNVSUpdates(tx.Data)
+	if err != nil {
+		return err
+	}
+	if len(updates) == 0 {
+		return fmt.Errorf("empty NVS update")
+	}
+	for _, update := range updates {
+		if update.Address == "" || update.Score == 0 || update.Score > MaxNVSScore {
+			return fmt.Errorf("invalid NVS score %d for %q", update.Score, update.Address)
+		}
+		if !isCanonicalAddress(update.Address) {
+			return fmt.Errorf("NVS update for %s, not a checksummed address", update.Address)
+		}
+	}
+	return nil
+}
+
+func (cm *ChainManager) executeNVSUpdate(state *stateDB, header *BlockHeader, tx *Transaction) ([]Log, error) {
+	if cm.config.NVSOracle == "" {
+		return nil, fmt.Errorf("no NVS oracle configured")
+	}
+	if !strings.EqualFold(tx.From, cm.config.NVSOracle) {
+		return nil, fmt.Errorf("%s is not the NVS oracle", tx.From)
+	}
+
+	updates, _ := DecodeNVSUpdates(tx.Data)
+	logs := make([]Log, 0, len(updates))
+	for _, update := range updates {
+		account, _ := state.getAccount(update.Address)
+		account.NVSScore = update.Score
+		state.setAccount(update.Address, account)
+		logs = append(logs, Log{
+			Address: tx.From,
+			Topics:  []string{NVSTopic, update.Address},
+			Data:    binary.BigEndian.AppendUint16(nil, update.Score),
+		})
+	}
+	return logs, nil
+}
@@ -0,0 +1,123 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+// The proposer of each block is drawn from the validator set of the parent
+// state, with odds proportional to bonded stake times NVS score. Every node
+// holding the parent state computes the same proposer and can check a
+// block's validator against it. Until a validator registers any key may
+// propose, so a new chain can bootstrap; the block must still be signed by
+// the validator it names.
+//
+// A height is split into rounds of proposerTimeout seconds counted from the
+// parent's timestamp, and the block's own timestamp says which round it was
+// proposed in. Each round draws again, so when the scheduled proposer is
+// offline another validator takes over once its round has passed.
+//
+// The draw is seeded by the grandparent hash rather than the parent's:
+// the parent's proposer chooses its header's timestamp and extra data, and
+// could otherwise try variants until the next draw picks a validator of
+// its choosing.
+
+// NextProposer returns the validator scheduled to propose a block on top of
+// the head now, or "" if anyone may
+func (cm *ChainManager) NextProposer() string {
+	return cm.ProposerAt(time.Now().Unix())
+}
+
+// ProposerAt returns the validator scheduled to propose a block with the
+// given timestamp on top of the head, or "" if anyone may
+func (cm *ChainManager) ProposerAt(timestamp int64) string {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+	proposer, _ := cm.scheduledProposer(cm.latestBlock(), timestamp)
+	return proposer
+}
+
+// scheduledProposer returns the proposer of a block with timestamp on top
+// of parent. The validators are the head's if parent is the head, and the
+// set recorded when parent was executed otherwise. The caller holds
+// cm.mutex.
+func (cm *ChainManager) scheduledProposer(parent *Block, timestamp int64) (string, error) {
+	validators := cm.validators()
+	if parent != cm.latestBlock() {
+		var err error
+		if validators, err = readValidatorSet(cm.db, parent.Hash()); err != nil {
+			return "", fmt.Errorf("validator set after block %d unknown: %v", parent.Header.Height, err)
+		}
+	}
+	return drawProposer(validators, proposerSeed(parent, proposerRound(cm.config, parent, timestamp))), nil
+}
+
+// drawProposer walks the cumulative weights up to a point drawn in
+// [0, total). Validators without weight are never drawn; when none has
+// any, the set is empty and anyone may propose.
+func drawProposer(validators []ValidatorInfo, seed Amount) string {
+	weights := make([]Amount, len(validators))
+	total := Amount{}
+	for i, validator := range validators {
+		weights[i] = proposerWeight(validator)
+		total, _ = total.Add(weights[i])
+	}
+	if total.IsZero() {
+		return ""
+	}
+
+	var target Amount
+	target.int().Mod(seed.int(), total.int())
+	for i, weight := range weights {
+		if target.Cmp(weight) < 0 {
+			return validators[i].Address
+		}
+		target, _ = target.Sub(weight)
+	}
+	return validators[len(validators)-1].Address
+}
+
+func proposerWeight(validator ValidatorInfo) Amount {
+	weight, err := validator.Stake.MulUint64(uint64(validator.NVSScore))
+	if err != nil {
+		return validator.Stake
+	}
+	return weight
+}
+
+// A round has to outlast the clock drift block timestamps are allowed, or
+// the next round's proposer could claim the height early by dating its
+// block ahead
+func proposerTimeout(config ChainConfig) int64 {
+	blockTime := int64(config.BlockTime)
+	if blockTime == 0 {
+		blockTime = defaultBlockTime
+	}
+	return 2 * (blockTime + maxFutureBlockTime)
+}
+
+// Round of a block with timestamp on top of parent: round 0 ends
+// proposerTimeout seconds after the parent, and so on
+func proposerRound(config ChainConfig, parent *Block, timestamp int64) uint64 {
+	elapsed := timestamp - parent.Header.Timestamp - 1
+	if elapsed < 0 {
+		return 0
+	}
+	return uint64(elapsed / proposerTimeout(config))
+}
+
+// sha256(grandparent hash || height of the next block || round), as a
+// 256-bit number
+func proposerSeed(parent *Block, round uint64) Amount {
+	hash, _ := hex.DecodeString(parent.Header.PrevHash)
+	hash = binary.BigEndian.AppendUint64(hash, parent.Header.Height+1)
+	hash = binary.BigEndian.AppendUint64(hash, round)
+	digest := sha256.Sum256(hash)
+
+	var seed Amount
+	seed.int().SetBytes32(digest[:])
+	return seed
+}
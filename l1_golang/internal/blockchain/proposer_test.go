@@ -0,0 +1,158 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// testParent is the i-th of a run of parents with distinct grandparents
+func testParent(i int, timestamp int64) *Block {
+	grandparent := sha256.Sum256([]byte(fmt.Sprint(i)))
+	return &Block{Header: BlockHeader{Height: 1, PrevHash: hex.EncodeToString(grandparent[:]), Timestamp: timestamp}}
+}
+
+func TestDrawProposerDeterministic(t *testing.T) {
+	validators := []ValidatorInfo{
+		{Address: "0x0000000000000000000000000000000000000001", Stake: NUSA(10), NVSScore: DefaultNVSScore},
+		{Address: "0x0000000000000000000000000000000000000002", Stake: NUSA(10), NVSScore: DefaultNVSScore},
+		{Address: "0x0000000000000000000000000000000000000003", Stake: NUSA(10), NVSScore: DefaultNVSScore},
+	}
+	parent := testParent(0, 1000)
+	want := drawProposer(validators, proposerSeed(parent, 0))
+	if want == "" {
+		t.Fatal("no proposer drawn")
+	}
+
+	// The parent's proposer controls its timestamp, not the seed
+	retimed := *parent
+	retimed.Header.Timestamp++
+	if got := drawProposer(validators, proposerSeed(&retimed, 0)); got != want {
+		t.Errorf("parent timestamp changed the draw from %s to %s", want, got)
+	}
+
+	// Height, round and grandparent each reseed it
+	seeds := map[Amount]string{proposerSeed(parent, 0): "base"}
+	moved := *parent
+	moved.Header.Height++
+	regrand := *parent
+	regrand.Header.PrevHash = testParent(1, 0).Header.PrevHash
+	for name, seed := range map[string]Amount{
+		"height":      proposerSeed(&moved, 0),
+		"round":       proposerSeed(parent, 1),
+		"grandparent": proposerSeed(&regrand, 0),
+	} {
+		if other, exists := seeds[seed]; exists {
+			t.Errorf("%s shares a seed with %s", name, other)
+		}
+		seeds[seed] = name
+	}
+}
+
+func TestDrawProposerWeights(t *testing.T) {
+	a := "0x0000000000000000000000000000000000000001"
+	b := "0x0000000000000000000000000000000000000002"
+	tests := []struct {
+		name       string
+		validators []ValidatorInfo
+		share      float64 // expected share of a
+	}{
+		{"equal", []ValidatorInfo{{Address: a, Stake: NUSA(10), NVSScore: 5000}, {Address: b, Stake: NUSA(10), NVSScore: 5000}}, 0.5},
+		{"stake", []ValidatorInfo{{Address: a, Stake: NUSA(30), NVSScore: 5000}, {Address: b, Stake: NUSA(10), NVSScore: 5000}}, 0.75},
+		{"NVS score", []ValidatorInfo{{Address: a, Stake: NUSA(10), NVSScore: 9000}, {Address: b, Stake: NUSA(10), NVSScore: 1000}}, 0.9},
+		{"stake against score", []ValidatorInfo{{Address: a, Stake: NUSA(10), NVSScore: 9000}, {Address: b, Stake: NUSA(90), NVSScore: 1000}}, 0.5},
+		{"no score", []ValidatorInfo{{Address: a, Stake: NUSA(10), NVSScore: 5000}, {Address: b, Stake: NUSA(90)}}, 1},
+	}
+	const draws = 4000
+	for _, test := range tests {
+		count := 0
+		for i := 0; i < draws; i++ {
+			switch drawProposer(test.validators, proposerSeed(testParent(i, 0), 0)) {
+			case a:
+				count++
+			case b:
+			default:
+				t.Fatalf("%s: drew outside the set", test.name)
+			}
+		}
+		if share := float64(count) / draws; share < test.share-0.03 || share > test.share+0.03 {
+			t.Errorf("%s: %s drawn %.3f of the time, want %.2f", test.name, a, share, test.share)
+		}
+	}
+}
+
+func TestDrawProposerEmptySet(t *testing.T) {
+	seed := proposerSeed(testParent(0, 0), 0)
+	unweighted := []ValidatorInfo{
+		{Address: "0x0000000000000000000000000000000000000001"},
+		{Address: "0x0000000000000000000000000000000000000002", Stake: NUSA(10)},
+	}
+	for name, validators := range map[string][]ValidatorInfo{"no validators": nil, "no weight": unweighted} {
+		if proposer := drawProposer(validators, seed); proposer != "" {
+			t.Errorf("%s: drew %s", name, proposer)
+		}
+	}
+}
+
+func TestProposerRound(t *testing.T) {
+	config := ChainConfig{BlockTime: 5}
+	timeout := proposerTimeout(config)
+	parent := testParent(0, 1000)
+	tests := []struct {
+		timestamp int64
+		round     uint64
+	}{
+		{999, 0},
+		{1001, 0},
+		{1000 + timeout, 0},
+		{1001 + timeout, 1},
+		{1000 + 2*timeout, 1},
+		{1001 + 2*timeout, 2},
+	}
+	for _, test := range tests {
+		if round := proposerRound(config, parent, test.timestamp); round != test.round {
+			t.Errorf("timestamp %d: round %d, want %d", test.timestamp, round, test.round)
+		}
+	}
+}
+
+func TestAddBlockChecksProposer(t *testing.T) {
+	c := newValidatorChain(t, 10, 10, 10, 10)
+	head := c.cm.GetLatestBlock()
+	timeout := proposerTimeout(c.config)
+
+	// A block signed by validator i for a round
+	block := func(i int, round int64) *Block {
+		block := NewBlock(head.Header.Height+1, head.Hash(), nil, c.addrs[i])
+		block.Header.Timestamp = head.Header.Timestamp + 1 + round*timeout
+		if err := c.cm.SealStateRoot(block); err != nil {
+			t.Fatal(err)
+		}
+		if err := block.Sign(c.keys[i]); err != nil {
+			t.Fatal(err)
+		}
+		return block
+	}
+
+	// Find a round whose proposer differs from round 0's
+	first := c.index(c.cm.ProposerAt(head.Header.Timestamp + 1))
+	later := int64(1)
+	for ; c.index(c.cm.ProposerAt(head.Header.Timestamp+1+later*timeout)) == first; later++ {
+	}
+	second := c.index(c.cm.ProposerAt(head.Header.Timestamp + 1 + later*timeout))
+
+	if err := c.cm.AddBlock(block(second, 0)); err == nil || !strings.Contains(err.Error(), "proposer") {
+		t.Errorf("block by %s in round 0: error %v", c.addrs[second], err)
+	}
+	if err := c.cm.AddBlock(block(first, later)); err == nil || !strings.Contains(err.Error(), "proposer") {
+		t.Errorf("round 0 proposer in round %d: error %v", later, err)
+	}
+	if head := c.cm.GetLatestBlock(); head.Header.Height != 0 {
+		t.Fatalf("head moved to #%d", head.Header.Height)
+	}
+	if err := c.cm.AddBlock(block(second, later)); err != nil {
+		t.Errorf("scheduled proposer of round %d: %v", later, err)
+	}
+}
@@ -3,10 +3,13 @@ package blockchain
 import (
 	"crypto/sha256"
 	"encoding/hex"
-	"encoding/json"
+	"fmt"
 	"time"
 )
 
+// Seconds a block's timestamp may run ahead of the local clock
+const maxFutureBlockTime = 10
+
 // Real Block Structure
 type Block struct {
 	Header       BlockHeader   `json:"header"`
@@ -26,21 +29,29 @@ type BlockHeader struct {
 	Difficulty     uint64    `json:"difficulty"`
 	GasLimit       uint64    `json:"gas_limit"`
 	GasUsed        uint64    `json:"gas_used"`
-	Reward         uint64    `json:"reward"`
+	Reward         Amount    `json:"reward"`
 	ExtraData      string    `json:"extra_data,omitempty"`
+	ReceiptsRoot   string    `json:"receipts_root"`
+	BaseFee        Amount    `json:"base_fee"`
 }
 
 type Transaction struct {
 	Hash        string          `json:"hash"`
+	Type        uint8           `json:"type"` // TxType*, selects validation and execution
+	ChainID     uint64          `json:"chain_id"` // network the sender signed for
 	Nonce       uint64          `json:"nonce"`
 	From        string          `json:"from"`
 	To          string          `json:"to"`
-	Value       uint64          `json:"value"`
-	GasPrice    uint64          `json:"gas_price"`
+	Value       Amount          `json:"value"`
+	GasPrice    Amount          `json:"gas_price"`
 	GasLimit    uint64          `json:"gas_limit"`
 	Data        []byte          `json:"data,omitempty"`
 	Signature   TransactionSig  `json:"signature"`
 	Timestamp   int64           `json:"timestamp"`
+	
+	// Dynamic fee fields; when both are zero GasPrice is used instead
+	MaxFeePerGas         Amount `json:"max_fee_per_gas"`
+	MaxPriorityFeePerGas Amount `json:"max_priority_fee_per_gas"`
 }
 
 type TransactionSig struct {
@@ -60,21 +71,21 @@ func NewBlock(height uint64, prevHash string, txs []Transaction, validator strin
 			Validator:  validator,
 			Difficulty: 1000000,
 			GasLimit:   8000000,
-			Reward:     2000000000000000000, // 2 NUSA
+			Reward:     NUSA(2),
 		},
 		Transactions: txs,
 	}
 	
 	// Calculate merkle root
+	// StateRoot and ReceiptsRoot are filled in by ChainManager.SealStateRoot once the block is final
 	block.Header.MerkleRoot = block.CalculateMerkleRoot()
-	block.Header.StateRoot = block.CalculateStateRoot()
 	
 	return block
 }
 
-// Calculate block hash
+// Calculate block hash over the canonical header encoding
 func (b *Block) Hash() string {
-	headerBytes, _ := json.Marshal(b.Header)
+	headerBytes, _ := EncodeHeader(&b.Header)
 	hash := sha256.Sum256(headerBytes)
 	return hex.EncodeToString(hash[:])
 }
@@ -85,124 +96,124 @@ func (b *Block) CalculateMerkleRoot() string {
 		return hex.EncodeToString(sha256.New().Sum(nil))
 	}
 	
-	var hashes []string
-	for _, tx := range b.Transactions {
-		hashes = append(hashes, tx.Hash)
-	}
-	
-	return buildMerkleTree(hashes)
+	return buildMerkleTree(b.txHashes())
 }
 
-func buildMerkleTree(hashes []string) string {
-	if len(hashes) == 1 {
-		return hashes[0]
-	}
-	
-	var newLevel []string
-	for i := 0; i < len(hashes); i += 2 {
-		if i+1 < len(hashes) {
-			combined := hashes[i] + hashes[i+1]
-			hash := sha256.Sum256([]byte(combined))
-			newLevel = append(newLevel, hex.EncodeToString(hash[:]))
-		} else {
-			// Duplicate last hash if odd number
-			combined := hashes[i] + hashes[i]
-			hash := sha256.Sum256([]byte(combined))
-			newLevel = append(newLevel, hex.EncodeToString(hash[:]))
+// Validate block against its parent, the proposer scheduled for its height
+// and round ("" if anyone may propose) and the state and receipts roots obtained by
+// executing it
+func (b *Block) Validate(prevBlock *Block, proposer, stateRoot, receiptsRoot string) bool {
+	return b.verify(prevBlock, proposer, stateRoot, receiptsRoot) == nil
+}
+
+// verify is Validate with the reason a block is rejected
+func (b *Block) verify(prevBlock *Block, proposer, stateRoot, receiptsRoot string) error {
+	if err := b.verifyHeader(prevBlock, proposer); err != nil {
+		return err
+	}
+	
+	// Check merkle root
+	if merkleRoot := b.CalculateMerkleRoot(); b.Header.MerkleRoot != merkleRoot {
+		return fmt.Errorf("merkle root %s, expected %s", b.Header.MerkleRoot, merkleRoot)
+	}
+	
+	// Check state root
+	if b.Header.StateRoot != stateRoot {
+		return fmt.Errorf("state root %s, executed %s", b.Header.StateRoot, stateRoot)
+	}
+	
+	// Check receipts root
+	if b.Header.ReceiptsRoot != receiptsRoot {
+		return fmt.Errorf("receipts root %s, executed %s", b.Header.ReceiptsRoot, receiptsRoot)
+	}
+	
+	// Validate all transactions
+	for i, tx := range b.Transactions {
+		if err := tx.verify(); err != nil {
+			return fmt.Errorf("transaction %d (%s): %v", i, tx.Hash, err)
 		}
 	}
 	
-	return buildMerkleTree(newLevel)
+	return nil
 }
 
-// Validate block
-func (b *Block) Validate(prevBlock *Block) bool {
+// verifyHeader holds the checks that need no execution: the link to the
+// parent, the timestamp, the signature and the scheduled proposer
+func (b *Block) verifyHeader(prevBlock *Block, proposer string) error {
 	// Check block hash
-	expectedHash := b.Hash()
+	if b.Header.Height > 0 && prevBlock == nil {
+		return fmt.Errorf("parent of block %d missing", b.Header.Height)
+	}
 	if b.Header.Height > 0 && b.Header.PrevHash != prevBlock.Hash() {
-		return false
+		return fmt.Errorf("previous hash %s does not match parent %s", b.Header.PrevHash, prevBlock.Hash())
+	}
+	if prevBlock != nil && b.Header.Height != prevBlock.Header.Height+1 {
+		return fmt.Errorf("height %d does not follow parent %d", b.Header.Height, prevBlock.Header.Height)
 	}
 	
 	// Check timestamp
-	if b.Header.Timestamp > time.Now().Unix()+10 {
-		return false // Future block
+	if b.Header.Timestamp > time.Now().Unix()+maxFutureBlockTime {
+		return fmt.Errorf("timestamp %d is in the future", b.Header.Timestamp)
 	}
 	
 	if prevBlock != nil && b.Header.Timestamp <= prevBlock.Header.Timestamp {
-		return false // Older than previous block
-	}
-	
-	// Check merkle root
-	if b.Header.MerkleRoot != b.CalculateMerkleRoot() {
-		return false
+		return fmt.Errorf("timestamp %d not after parent %d", b.Header.Timestamp, prevBlock.Header.Timestamp)
 	}
 	
-	// Validate all transactions
-	for _, tx := range b.Transactions {
-		if !tx.Validate() {
-			return false
+	// Every block after genesis is signed by its validator, who must be
+	// the proposer scheduled for the height
+	if b.Header.Height > 0 {
+		if !isCanonicalAddress(b.Header.Validator) {
+			return fmt.Errorf("validator %s is not a checksummed address", b.Header.Validator)
+		}
+		if !b.VerifySignature() {
+			return fmt.Errorf("signature does not recover to validator %s", b.Header.Validator)
+		}
+		if proposer != "" && b.Header.Validator != proposer {
+			return fmt.Errorf("validator %s is not the scheduled proposer %s", b.Header.Validator, proposer)
 		}
 	}
 	
-	return true
+	return nil
 }
 
 // Validate transaction
 func (tx *Transaction) Validate() bool {
+	return tx.verify() == nil
+}
+
+// verify is Validate with the reason a transaction is rejected
+func (tx *Transaction) verify() error {
 	// Basic validation
-	if tx.From == "" || tx.Value == 0 {
-		return false
+	if tx.From == "" {
+		return fmt.Errorf("missing sender")
+	}
+	if !isCanonicalAddress(tx.From) {
+		return fmt.Errorf("sender %s is not a checksummed address", tx.From)
+	}
+	if tx.To != "" && !isCanonicalAddress(tx.To) {
+		return fmt.Errorf("recipient %s is not a checksummed address", tx.To)
+	}
+	if err := tx.validateType(); err != nil {
+		return err
 	}
 	
 	// Check hash
 	if tx.Hash != tx.CalculateHash() {
-		return false
+		return fmt.Errorf("hash does not match payload")
 	}
 	
-	// TODO: Verify signature
-	// if !tx.VerifySignature() { return false }
+	// Signature must recover to the sender
+	if !tx.VerifySignature() {
+		return fmt.Errorf("signature does not recover to sender")
+	}
 	
-	return true
+	return nil
 }
 
+// Hash of the canonical unsigned payload; this is what senders sign
 func (tx *Transaction) CalculateHash() string {
-	data := struct {
-		Nonce    uint64 `json:"nonce"`
-		From     string `json:"from"`
-		To       string `json:"to"`
-		Value    uint64 `json:"value"`
-		GasPrice uint64 `json:"gas_price"`
-		GasLimit uint64 `json:"gas_limit"`
-		Data     []byte `json:"data,omitempty"`
-	}{
-		Nonce:    tx.Nonce,
-		From:     tx.From,
-		To:       tx.To,
-		Value:    tx.Value,
-		GasPrice: tx.GasPrice,
-		GasLimit: tx.GasLimit,
-		Data:     tx.Data,
-	}
-	
-	bytes, _ := json.Marshal(data)
+	bytes, _ := encodeVersioned(txPayload(tx))
 	hash := sha256.Sum256(bytes)
 	return hex.EncodeToString(hash[:])
 }
-
-func (b *Block) CalculateStateRoot() string {
-	// Simplified state root calculation
-	// In production, use Patricia Merkle Tree
-	stateData := struct {
-		Height    uint64 `json:"height"`
-		Timestamp int64  `json:"timestamp"`
-		TxCount   int    `json:"tx_count"`
-	}{
-		Height:    b.Header.Height,
-		Timestamp: b.Header.Timestamp,
-		TxCount:   len(b.Transactions),
-	}
-	
-	bytes, _ := json.Marshal(stateData)
-	hash := sha256.Sum256(bytes)
-	return hex.EncodeToString(hash[:])
-}
\ No newline at end of file
@@ -0,0 +1,77 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+const (
+	ReceiptStatusFailed     = uint64(0)
+	ReceiptStatusSuccessful = uint64(1)
+)
+
+// Topic of the log every successful transfer emits
+const TransferTopic = "Transfer"
+
+// Receipt records the outcome of one included transaction. The block
+// fields are filled in once the block is on the canonical chain and are
+// not part of the receipts root.
+type Receipt struct {
+	TxHash            string `json:"tx_hash"`
+	Status            uint64 `json:"status"`
+	GasUsed           uint64 `json:"gas_used"`
+	CumulativeGasUsed uint64 `json:"cumulative_gas_used"`
+	Fee               Amount `json:"fee"`
+	Logs              []Log  `json:"logs"`
+	Error             string `json:"error,omitempty"`
+
+	BlockHash   string `json:"block_hash"`
+	BlockHeight uint64 `json:"block_height"`
+	TxIndex     uint64 `json:"tx_index"`
+}
+
+type Log struct {
+	Address string   `json:"address"`
+	Topics  []string `json:"topics"`
+	Data    []byte   `json:"data,omitempty"`
+}
+
+// Hash over the consensus fields of the receipt
+func (r *Receipt) Hash() string {
+	data, _ := EncodeReceipt(r)
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:])
+}
+
+// DeriveReceiptsRoot builds the merkle root BlockHeader.ReceiptsRoot commits to
+func DeriveReceiptsRoot(receipts []*Receipt) string {
+	if len(receipts) == 0 {
+		return hex.EncodeToString(sha256.New().Sum(nil))
+	}
+
+	var hashes []string
+	for _, receipt := range receipts {
+		hashes = append(hashes, receipt.Hash())
+	}
+
+	return buildMerkleTree(hashes)
+}
+
+// Gas used by the whole block
+func blockGasUsed(receipts []*Receipt) uint64 {
+	if len(receipts) == 0 {
+		return 0
+	}
+	return receipts[len(receipts)-1].CumulativeGasUsed
+}
+
+// Log emitted by a native transfer: topics are the event, sender and
+// recipient, data is the 32-byte big-endian value
+func transferLog(tx Transaction) Log {
+	value := tx.Value.int().Bytes32()
+	return Log{
+		Address: tx.To,
+		Topics:  []string{TransferTopic, tx.From, tx.To},
+		Data:    value[:],
+	}
+}
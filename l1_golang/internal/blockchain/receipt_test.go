@@ -0,0 +1,95 @@
+package blockchain
+
+import (
+	"testing"
+)
+
+func TestBlockReceipts(t *testing.T) {
+	c := newTestChain(t, 2)
+	recipient := keyAddress(newTestKey(t))
+
+	memo := c.transfer(0, 1, recipient, NUSA(1))
+	memo.Data = []byte("nusa")
+	memo.GasLimit = IntrinsicGas(memo.Data)
+	if err := memo.Sign(c.keys[0]); err != nil {
+		t.Fatal(err)
+	}
+	txs := []Transaction{
+		c.transfer(0, 0, recipient, NUSA(2)),
+		memo,
+		c.transfer(1, 0, recipient, NUSA(1000)),
+	}
+	tests := []struct {
+		name    string
+		status  uint64
+		gasUsed uint64
+		logs    int
+	}{
+		{"transfer", ReceiptStatusSuccessful, TxGas, 1},
+		{"transfer with data", ReceiptStatusSuccessful, IntrinsicGas(memo.Data), 1},
+		{"transfer above the balance", ReceiptStatusFailed, TxGas, 0},
+	}
+	block := c.mine(txs...)
+
+	var receipts []*Receipt
+	cumulative := uint64(0)
+	for i, test := range tests {
+		tx := txs[i]
+		receipt, err := c.cm.GetReceipt(tx.Hash)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		receipts = append(receipts, receipt)
+		cumulative += test.gasUsed
+
+		if receipt.Status != test.status || receipt.GasUsed != test.gasUsed || receipt.CumulativeGasUsed != cumulative {
+			t.Errorf("%s: status %d gas %d cumulative %d, want %d %d %d", test.name,
+				receipt.Status, receipt.GasUsed, receipt.CumulativeGasUsed, test.status, test.gasUsed, cumulative)
+		}
+		if (receipt.Error != "") != (test.status == ReceiptStatusFailed) {
+			t.Errorf("%s: error %q with status %d", test.name, receipt.Error, receipt.Status)
+		}
+		if receipt.BlockHash != block.Hash() || receipt.BlockHeight != block.Header.Height || receipt.TxIndex != uint64(i) {
+			t.Errorf("%s: located at %s #%d index %d", test.name, receipt.BlockHash, receipt.BlockHeight, receipt.TxIndex)
+		}
+		fee, _ := tx.EffectiveGasPrice(block.Header.BaseFee).MulUint64(test.gasUsed)
+		if receipt.Fee != fee {
+			t.Errorf("%s: fee %s, want %s", test.name, receipt.Fee, fee)
+		}
+
+		if len(receipt.Logs) != test.logs {
+			t.Fatalf("%s: %d logs, want %d", test.name, len(receipt.Logs), test.logs)
+		}
+		if test.logs > 0 {
+			log := receipt.Logs[0]
+			value := tx.Value.int().Bytes32()
+			if len(log.Topics) != 3 || log.Topics[0] != TransferTopic || log.Topics[1] != tx.From || log.Topics[2] != tx.To || string(log.Data) != string(value[:]) {
+				t.Errorf("%s: log %+v", test.name, log)
+			}
+		}
+	}
+
+	if block.Header.GasUsed != cumulative {
+		t.Errorf("block gas used %d, want %d", block.Header.GasUsed, cumulative)
+	}
+	if root := DeriveReceiptsRoot(receipts); block.Header.ReceiptsRoot != root {
+		t.Errorf("receipts root %s, receipts give %s", block.Header.ReceiptsRoot, root)
+	}
+
+	// The failed transfer still paid for its gas
+	account := c.cm.State[c.addrs[1]]
+	want, _ := NUSA(100).Sub(receipts[2].Fee)
+	if account.Balance != want || account.Nonce != 1 {
+		t.Errorf("failed sender has balance %s nonce %d, want %s 1", account.Balance, account.Nonce, want)
+	}
+
+	// Receipts leave with their block
+	if _, err := c.cm.RevertBlocks(1); err != nil {
+		t.Fatal(err)
+	}
+	for i, tx := range txs {
+		if _, err := c.cm.GetReceipt(tx.Hash); err == nil {
+			t.Errorf("%s: receipt kept after revert", tests[i].name)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package blockchain
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// Sign computes the transaction hash and attaches a recoverable secp256k1
+// signature over it. From must be the address of prv.
+func (tx *Transaction) Sign(prv *ecdsa.PrivateKey) error {
+	from := crypto.PubkeyToAddress(prv.PublicKey)
+	if !common.IsHexAddress(tx.From) || common.HexToAddress(tx.From) != from {
+		return fmt.Errorf("signer %s does not match sender %s", from.Hex(), tx.From)
+	}
+
+	tx.Hash = tx.CalculateHash()
+	hash, _ := hex.DecodeString(tx.Hash)
+
+	sig, err := crypto.Sign(hash, prv)
+	if err != nil {
+		return err
+	}
+
+	tx.Signature = TransactionSig{
+		R: hex.EncodeToString(sig[:32]),
+		S: hex.EncodeToString(sig[32:64]),
+		V: sig[64],
+	}
+	return nil
+}
+
+// Sender recovers the address that signed the transaction hash
+func (tx *Transaction) Sender() (common.Address, error) {
+	hash, err := hex.DecodeString(tx.Hash)
+	if err != nil || len(hash) != 32 {
+		return common.Address{}, fmt.Errorf("invalid transaction hash")
+	}
+
+	sig, err := tx.Signature.bytes()
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	pub, err := crypto.SigToPub(hash, sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+// VerifySignature checks that the signature recovers to From
+func (tx *Transaction) VerifySignature() bool {
+	if !common.IsHexAddress(tx.From) {
+		return false
+	}
+
+	sender, err := tx.Sender()
+	if err != nil {
+		return false
+	}
+	return sender == common.HexToAddress(tx.From)
+}
+
+// Sign attaches a recoverable secp256k1 signature over the block hash, hex
+// encoded as [R || S || V]. Header.Validator must be the address of prv,
+// and the header must be final: signing covers every header field.
+func (b *Block) Sign(prv *ecdsa.PrivateKey) error {
+	validator := crypto.PubkeyToAddress(prv.PublicKey)
+	if !common.IsHexAddress(b.Header.Validator) || common.HexToAddress(b.Header.Validator) != validator {
+		return fmt.Errorf("signer %s does not match validator %s", validator.Hex(), b.Header.Validator)
+	}
+
+	sig, err := signHash(b.Hash(), prv)
+	if err != nil {
+		return err
+	}
+	b.Signature = sig
+	return nil
+}
+
+// Signer recovers the address that signed the block hash
+func (b *Block) Signer() (common.Address, error) {
+	signer, err := recoverSigner(b.Hash(), b.Signature)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("invalid block signature: %v", err)
+	}
+	return signer, nil
+}
+
+// VerifySignature checks that the signature recovers to Header.Validator
+func (b *Block) VerifySignature() bool {
+	if !common.IsHexAddress(b.Header.Validator) {
+		return false
+	}
+
+	signer, err := b.Signer()
+	if err != nil {
+		return false
+	}
+	return signer == common.HexToAddress(b.Header.Validator)
+}
+
+// Sign signs the vote hash the same way blocks are signed; Validator must
+// be the address of prv
+func (v *Vote) Sign(prv *ecdsa.PrivateKey) error {
+	validator := crypto.PubkeyToAddress(prv.PublicKey)
+	if !common.IsHexAddress(v.Validator) || common.HexToAddress(v.Validator) != validator {
+		return fmt.Errorf("signer %s does not match validator %s", validator.Hex(), v.Validator)
+	}
+
+	sig, err := signHash(v.Hash(), prv)
+	if err != nil {
+		return err
+	}
+	v.Signature = sig
+	return nil
+}
+
+// Signer recovers the address that signed the vote hash
+func (v *Vote) Signer() (common.Address, error) {
+	signer, err := recoverSigner(v.Hash(), v.Signature)
+	if err != nil {
+		return common.Address{}, fmt.Errorf("invalid vote signature: %v", err)
+	}
+	return signer, nil
+}
+
+// signHash signs a hex hash and returns the hex [R || S || V] signature
+func signHash(hash string, prv *ecdsa.PrivateKey) (string, error) {
+	raw, _ := hex.DecodeString(hash)
+	sig, err := crypto.Sign(raw, prv)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sig), nil
+}
+
+// recoverSigner recovers the address behind a signature made by signHash
+func recoverSigner(hash, signature string) (common.Address, error) {
+	raw, err := hex.DecodeString(strings.TrimPrefix(signature, "0x"))
+	if err != nil || len(raw) != 65 {
+		return common.Address{}, fmt.Errorf("not 65 hex bytes")
+	}
+	sig, err := TransactionSig{
+		R: hex.EncodeToString(raw[:32]),
+		S: hex.EncodeToString(raw[32:64]),
+		V: raw[64],
+	}.bytes()
+	if err != nil {
+		return common.Address{}, err
+	}
+
+	digest, _ := hex.DecodeString(hash)
+	pub, err := crypto.SigToPub(digest, sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pub), nil
+}
+
+// bytes returns the 65-byte [R || S || V] form expected by crypto.SigToPub.
+// V may be the raw recovery id (0/1) or the legacy 27/28 form.
+func (sig TransactionSig) bytes() ([]byte, error) {
+	r, err := decodeSigScalar(sig.R)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature r: %v", err)
+	}
+	s, err := decodeSigScalar(sig.S)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature s: %v", err)
+	}
+
+	v := sig.V
+	if v >= 27 {
+		v -= 27
+	}
+	if !crypto.ValidateSignatureValues(v, r, s, true) {
+		return nil, fmt.Errorf("invalid signature values")
+	}
+
+	out := make([]byte, 65)
+	r.FillBytes(out[:32])
+	s.FillBytes(out[32:64])
+	out[64] = v
+	return out, nil
+}
+
+func decodeSigScalar(value string) (*big.Int, error) {
+	raw, err := hex.DecodeString(strings.TrimPrefix(value, "0x"))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 || len(raw) > 32 {
+		return nil, fmt.Errorf("bad length %d", len(raw))
+	}
+	return new(big.Int).SetBytes(raw), nil
+}
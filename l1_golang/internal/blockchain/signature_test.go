@@ -0,0 +1,134 @@
+package blockchain
+
+import (
+	"encoding/hex"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+func TestTransactionSignature(t *testing.T) {
+	c := newTestChain(t, 2)
+	recipient := keyAddress(newTestKey(t))
+
+	tests := []struct {
+		name   string
+		edit   func(tx *Transaction)
+		reason string
+	}{
+		{"forged sender", func(tx *Transaction) {
+			// Account 0 signs a transfer spending account 1's balance
+			tx.From = c.addrs[1]
+			tx.Hash = tx.CalculateHash()
+			hash, _ := hex.DecodeString(tx.Hash)
+			sig, err := crypto.Sign(hash, c.keys[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			tx.Signature = TransactionSig{R: hex.EncodeToString(sig[:32]), S: hex.EncodeToString(sig[32:64]), V: sig[64]}
+		}, "signature"},
+		{"high s", func(tx *Transaction) {
+			// (r, n-s) with the other recovery id is the same signature's
+			// malleated twin
+			s, _ := new(big.Int).SetString(tx.Signature.S, 16)
+			s.Sub(crypto.S256().Params().N, s)
+			tx.Signature.S = hex.EncodeToString(s.Bytes())
+			tx.Signature.V ^= 1
+		}, "signature"},
+		{"v 2", func(tx *Transaction) { tx.Signature.V = 2 }, "signature"},
+		{"v 29", func(tx *Transaction) { tx.Signature.V = 29 }, "signature"},
+		{"v flipped", func(tx *Transaction) { tx.Signature.V ^= 1 }, "signature"},
+		{"changed after signing", func(tx *Transaction) {
+			tx.Value = NUSA(6)
+			tx.Hash = tx.CalculateHash()
+		}, "signature"},
+		{"wrong chain", func(tx *Transaction) {
+			tx.ChainID = c.config.ChainID + 1
+			if err := tx.Sign(c.keys[0]); err != nil {
+				t.Fatal(err)
+			}
+		}, "chain"},
+	}
+	for _, test := range tests {
+		tx := c.transfer(0, 0, recipient, NUSA(5))
+		test.edit(&tx)
+		if err := c.cm.AddTransaction(tx); err == nil || !strings.Contains(err.Error(), test.reason) {
+			t.Errorf("%s: error %v, want %q", test.name, err, test.reason)
+		}
+	}
+	if pending := c.cm.GetPendingTXs(); len(pending) != 0 {
+		t.Fatalf("pending %v", pending)
+	}
+
+	// V is accepted as the raw recovery id and in the legacy 27/28 form
+	for i, legacy := range []bool{false, true} {
+		tx := c.transfer(i, 0, recipient, NUSA(5))
+		if tx.Signature.V > 1 {
+			t.Fatalf("signed with v %d", tx.Signature.V)
+		}
+		if legacy {
+			tx.Signature.V += 27
+		}
+		if !tx.VerifySignature() {
+			t.Errorf("v %d does not verify", tx.Signature.V)
+		}
+		if err := c.cm.AddTransaction(tx); err != nil {
+			t.Errorf("v %d: %v", tx.Signature.V, err)
+		}
+	}
+}
+
+func TestBlockSignature(t *testing.T) {
+	c := newValidatorChain(t, 10, 10, 10, 10)
+	outsider := newTestKey(t)
+	head := c.cm.GetLatestBlock()
+
+	block := c.build()
+	signer, err := block.Signer()
+	if err != nil || signer.Hex() != block.Header.Validator || !block.VerifySignature() {
+		t.Fatalf("signer %s (%v), validator %s", signer.Hex(), err, block.Header.Validator)
+	}
+	if err := block.Sign(outsider); err == nil {
+		t.Error("signed with a key other than the validator's")
+	}
+
+	tests := []struct {
+		name   string
+		edit   func(block *Block)
+		reason string
+	}{
+		{"unsigned", func(block *Block) { block.Signature = "" }, "signature"},
+		{"malformed signature", func(block *Block) { block.Signature = "zz" }, "signature"},
+		{"non-validator", func(block *Block) {
+			block.Header.Validator = keyAddress(outsider)
+			if err := block.Sign(outsider); err != nil {
+				t.Fatal(err)
+			}
+		}, "scheduled proposer"},
+		{"state root changed after signing", func(block *Block) { block.Header.StateRoot = testHashes(1)[0] }, "signature"},
+		{"timestamp changed after signing", func(block *Block) { block.Header.Timestamp++ }, "signature"},
+		{"validator changed after signing", func(block *Block) {
+			block.Header.Validator = c.addrs[(c.index(block.Header.Validator)+1)%len(c.addrs)]
+		}, "signature"},
+	}
+	for _, test := range tests {
+		block := c.build()
+		test.edit(block)
+		err := c.cm.AddBlock(block)
+		if err == nil || !strings.Contains(err.Error(), test.reason) {
+			t.Errorf("%s: error %v, want %q", test.name, err, test.reason)
+		}
+		if c.cm.GetLatestBlock() != head {
+			t.Fatalf("%s: head moved", test.name)
+		}
+	}
+
+	if err := block.verifyHeader(nil, ""); err == nil {
+		t.Error("block without a parent verified")
+	}
+	if err := c.cm.AddBlock(block); err != nil {
+		t.Fatalf("signed block: %v", err)
+	}
+}
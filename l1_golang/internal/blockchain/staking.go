@@ -0,0 +1,163 @@
+package blockchain
+
+import (
+	"fmt"
+	"sort"
+)
+
+// An account can have this many unstakes in flight at once
+const MaxUnbondingEntries = 16
+
+// Unbonding is unstaked NUSA on its way back to the balance. It no longer
+// backs a validator but stays locked until the block at ReleaseHeight,
+// which pays it out before running its transactions.
+type Unbonding struct {
+	Amount        Amount `json:"amount"`
+	ReleaseHeight uint64 `json:"release_height"`
+}
+
+// ValidatorInfo is a registered validator and the stake behind it
+type ValidatorInfo struct {
+	Address    string `json:"address"`
+	Stake      Amount `json:"stake"` // own and delegated
+	SelfStake  Amount `json:"self_stake"`
+	Delegated  Amount `json:"delegated"`
+	Commission uint16 `json:"commission"` // basis points
+	Delegators int    `json:"delegators"`
+	NVSScore   uint16 `json:"nvs_score"` // basis points, DefaultNVSScore if none was posted
+	LastActive int64  `json:"last_active"`
+}
+
+// GetValidators returns the registered validators that still have stake
+// of their own at the head, sorted by address
+func (cm *ChainManager) GetValidators() []ValidatorInfo {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+	return cm.validators()
+}
+
+// validators assumes the caller holds cm.mutex
+func (cm *ChainManager) validators() []ValidatorInfo {
+	return activeValidators(cm.State, nil)
+}
+
+// activeValidators lists the validators of base with the accounts in
+// dirty applied on top, sorted by address
+func activeValidators(base, dirty map[string]AccountState) []ValidatorInfo {
+	validators := []ValidatorInfo{}
+	add := func(address string, account AccountState) {
+		if account.Validator && !account.Stake.IsZero() {
+			validators = append(validators, validatorInfo(address, account))
+		}
+	}
+	for address, account := range base {
+		if _, changed := dirty[address]; !changed {
+			add(address, account)
+		}
+	}
+	for address, account := range dirty {
+		add(address, account)
+	}
+	sort.Slice(validators, func(i, j int) bool { return validators[i].Address < validators[j].Address })
+	return validators
+}
+
+func validatorInfo(address string, account AccountState) ValidatorInfo {
+	info := ValidatorInfo{
+		Address:    address,
+		Stake:      bondedStake(account),
+		SelfStake:  account.Stake,
+		Commission: account.Commission,
+		Delegators: len(account.Delegations),
+		NVSScore:   nvsScore(account),
+		LastActive: account.LastActive,
+	}
+	info.Delegated, _ = info.Stake.Sub(account.Stake)
+	return info
+}
+
+// unbond moves value from the account's stake into an unbonding entry
+// released at height
+func unbond(account *AccountState, value Amount, height uint64) error {
+	stake, err := account.Stake.Sub(value)
+	if err != nil {
+		return fmt.Errorf("stake %s below %s", account.Stake, value)
+	}
+	if err := addUnbonding(account, value, height); err != nil {
+		return err
+	}
+	account.Stake = stake
+	return nil
+}
+
+// addUnbonding queues value for release to the account's balance at height
+func addUnbonding(account *AccountState, value Amount, height uint64) error {
+	if len(account.Unbonding) >= MaxUnbondingEntries {
+		return fmt.Errorf("%d unstakes already unbonding", len(account.Unbonding))
+	}
+
+	// Copy so the entries of the committed account are left untouched
+	unbonding := make([]Unbonding, len(account.Unbonding), len(account.Unbonding)+1)
+	copy(unbonding, account.Unbonding)
+	account.Unbonding = append(unbonding, Unbonding{Amount: value, ReleaseHeight: height})
+	return nil
+}
+
+// indexUnbonding lists address under the height each of its unbonding
+// entries is released at. Entries already matured are due in the next
+// block. Addresses are not removed when an entry goes, so releaseUnbonded
+// re-reads every account it visits.
+func (cm *ChainManager) indexUnbonding(address string, account AccountState, next uint64) {
+	for _, entry := range account.Unbonding {
+		height := entry.ReleaseHeight
+		if height < next {
+			height = next
+		}
+		if cm.unbonding[height] == nil {
+			cm.unbonding[height] = make(map[string]bool)
+		}
+		cm.unbonding[height][address] = true
+	}
+}
+
+// releaseUnbonded pays every unbonding entry that matures at height back
+// to its account's balance. Only accounts indexed under height are visited.
+func (cm *ChainManager) releaseUnbonded(state *stateDB, height uint64) error {
+	for address := range cm.unbonding[height] {
+		account, _ := state.getAccount(address)
+
+		var pending []Unbonding
+		released := Amount{}
+		for _, entry := range account.Unbonding {
+			if entry.ReleaseHeight > height {
+				pending = append(pending, entry)
+				continue
+			}
+			var err error
+			if released, err = released.Add(entry.Amount); err != nil {
+				return fmt.Errorf("unbonding of %s: %v", address, err)
+			}
+		}
+		if released.IsZero() {
+			continue
+		}
+
+		balance, err := account.Balance.Add(released)
+		if err != nil {
+			return fmt.Errorf("unbonding of %s: %v", address, err)
+		}
+		account.Balance = balance
+		account.Unbonding = pending
+		state.setAccount(address, account)
+	}
+	return nil
+}
+
+// Total of the account's unbonding entries
+func unbondingTotal(account AccountState) Amount {
+	total := Amount{}
+	for _, entry := range account.Unbonding {
+		total, _ = total.Add(entry.Amount)
+	}
+	return total
+}
@@ -0,0 +1,78 @@
+package blockchain
+
+import (
+	"testing"
+
+	"nusa-chain/internal/storage"
+)
+
+// typed signs account i's transaction of type typ
+func (c *testChain) typed(i int, nonce uint64, typ uint8, to string, value Amount) Transaction {
+	tx := c.transfer(i, nonce, to, value)
+	tx.Type = typ
+	if err := tx.Sign(c.keys[i]); err != nil {
+		c.t.Fatal(err)
+	}
+	return tx
+}
+
+func TestUnbondingReleased(t *testing.T) {
+	tests := []struct {
+		name   string
+		period uint64
+	}{
+		{"after the unbonding period", 3},
+		{"no unbonding period", 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := storage.NewMemoryDB()
+			c := newTestChain(t, 1)
+			c.config.GenesisAccounts[0].Stake = NUSA(10)
+			c.config.UnbondingPeriod = test.period
+			c = c.fork(db)
+
+			// An entry maturing in its own block is released by the next
+			c.mine(c.typed(0, 0, TxTypeUnstake, "", NUSA(4)))
+			release := 1 + test.period
+			if release < 2 {
+				release = 2
+			}
+			bonded := c.cm.State[c.addrs[0]]
+			for c.cm.GetLatestBlock().Header.Height < release-1 {
+				c.mine()
+			}
+			if account := c.cm.State[c.addrs[0]]; account.Balance != bonded.Balance || len(account.Unbonding) != 1 {
+				t.Fatalf("released before #%d: %+v", release, account)
+			}
+
+			released, _ := bonded.Balance.Add(NUSA(4))
+			check := func(when string) {
+				t.Helper()
+				if account := c.cm.State[c.addrs[0]]; account.Balance != released || len(account.Unbonding) != 0 {
+					t.Fatalf("%s: %+v, want balance %s", when, account, released)
+				}
+			}
+			c.mine()
+			check("release block")
+
+			// Reverting the release puts the entry back in line for it
+			if _, err := c.cm.RevertBlocks(1); err != nil {
+				t.Fatal(err)
+			}
+			if account := c.cm.State[c.addrs[0]]; len(account.Unbonding) != 1 {
+				t.Fatalf("entry not restored: %+v", account)
+			}
+			c.mine()
+			check("release block after revert")
+
+			// So does reopening the datadir
+			if _, err := c.cm.RevertBlocks(1); err != nil {
+				t.Fatal(err)
+			}
+			c = c.fork(db)
+			c.mine()
+			check("release block after reopening")
+		})
+	}
+}
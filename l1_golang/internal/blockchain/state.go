@@ -0,0 +1,162 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/rlp"
+
+	"nusa-chain/internal/storage"
+	"nusa-chain/internal/trie"
+)
+
+// stateDB stages the account changes of one block on top of the committed
+// state. Nothing reaches ChainManager.State until commit, and every write is
+// journaled so a failed step can be rolled back to a snapshot.
+type stateDB struct {
+	base     map[string]AccountState
+	dirty    map[string]AccountState
+	baseTrie *trie.Trie
+	trie     *trie.Trie
+	journal  []journalEntry
+}
+
+// journalEntry remembers what an address held in dirty before a write
+type journalEntry struct {
+	address string
+	prev    AccountState
+	hadPrev bool
+}
+
+// accountUndo restores one account when a block is reverted
+type accountUndo struct {
+	Address string       `json:"address"`
+	State   AccountState `json:"state"`
+	Existed bool         `json:"existed"`
+}
+
+func (cm *ChainManager) newStateDB() *stateDB {
+	return &stateDB{
+		base:     cm.State,
+		dirty:    make(map[string]AccountState),
+		baseTrie: cm.stateTrie,
+		trie:     cm.stateTrie.Copy(),
+	}
+}
+
+func (s *stateDB) getAccount(address string) (AccountState, bool) {
+	if state, exists := s.dirty[address]; exists {
+		return state, true
+	}
+	state, exists := s.base[address]
+	return state, exists
+}
+
+func (s *stateDB) setAccount(address string, state AccountState) {
+	prev, hadPrev := s.dirty[address]
+	s.journal = append(s.journal, journalEntry{address: address, prev: prev, hadPrev: hadPrev})
+	s.dirty[address] = state
+}
+
+func (s *stateDB) snapshot() int {
+	return len(s.journal)
+}
+
+// Undo every write made after the snapshot was taken
+func (s *stateDB) revertToSnapshot(id int) {
+	for i := len(s.journal) - 1; i >= id; i-- {
+		entry := s.journal[i]
+		if entry.hadPrev {
+			s.dirty[entry.address] = entry.prev
+		} else {
+			delete(s.dirty, entry.address)
+		}
+	}
+	s.journal = s.journal[:id]
+}
+
+// Pre-block values of every account this stateDB touched
+func (s *stateDB) undoRecord() []accountUndo {
+	undo := make([]accountUndo, 0, len(s.dirty))
+	for address := range s.dirty {
+		prev, existed := s.base[address]
+		undo = append(undo, accountUndo{Address: address, State: prev, Existed: existed})
+	}
+	return undo
+}
+
+// Hash dirty accounts into the trie and return the post-state root
+func (s *stateDB) intermediateRoot() (string, error) {
+	// Rebuild from the base trie so reverted writes leave no trace
+	s.trie = s.baseTrie.Copy()
+	for address, state := range s.dirty {
+		if err := s.trie.Update(stateKey(address), encodeAccount(state)); err != nil {
+			return "", err
+		}
+	}
+	return s.trie.Hash()
+}
+
+// Stage trie nodes and account records into batch
+func (s *stateDB) commit(batch storage.Batch) (string, error) {
+	if _, err := s.intermediateRoot(); err != nil {
+		return "", err
+	}
+	for address, state := range s.dirty {
+		if err := writeAccount(batch, address, state); err != nil {
+			return "", err
+		}
+	}
+	return s.trie.Commit(batch)
+}
+
+// Trie keys are hashed so the trie stays balanced whatever the address format
+func stateKey(address string) []byte {
+	hash := sha256.Sum256([]byte(address))
+	return hash[:]
+}
+
+// Only consensus fields go into the trie; LastActive is local wall-clock
+// bookkeeping and would make every node compute a different root.
+type trieAccount struct {
+	Balance Amount
+	Nonce   uint64
+	Stake   Amount
+
+	Validator   bool         `rlp:"optional"`
+	Unbonding   []Unbonding  `rlp:"optional"`
+	Commission  uint16       `rlp:"optional"`
+	Delegations []Delegation `rlp:"optional"`
+	NVSScore    uint16       `rlp:"optional"`
+}
+
+func encodeAccount(state AccountState) []byte {
+	return encodeTrieAccount(trieAccount{
+		Balance: state.Balance,
+		Nonce:   state.Nonce,
+		Stake:   state.Stake,
+
+		Validator:   state.Validator,
+		Unbonding:   state.Unbonding,
+		Commission:  state.Commission,
+		Delegations: state.Delegations,
+		NVSScore:    state.NVSScore,
+	})
+}
+
+func encodeTrieAccount(account trieAccount) []byte {
+	data, _ := rlp.EncodeToBytes(account)
+	return data
+}
+
+// decodeAccount reads a trie value; no value is the zero account
+func decodeAccount(data []byte) (trieAccount, error) {
+	var account trieAccount
+	if len(data) == 0 {
+		return account, nil
+	}
+	if err := rlp.DecodeBytes(data, &account); err != nil {
+		return account, fmt.Errorf("corrupt account: %v", err)
+	}
+	return account, nil
+}
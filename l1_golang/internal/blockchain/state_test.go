@@ -0,0 +1,34 @@
+package blockchain
+
+import (
+	"testing"
+)
+
+func TestStateRootCommitsToBalances(t *testing.T) {
+	c := newTestChain(t, 2)
+	roots := make(map[string]Amount)
+	for _, balance := range []Amount{NUSA(100), NewAmount(1), Amount{}} {
+		state := c.cm.newStateDB()
+		account, _ := state.getAccount(c.addrs[0])
+		account.Balance = balance
+		state.setAccount(c.addrs[0], account)
+		root, err := state.intermediateRoot()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if other, exists := roots[root]; exists {
+			t.Errorf("balances %s and %s give root %s", other, balance, root)
+		}
+		roots[root] = balance
+	}
+
+	// Genesis states one wei apart build different headers
+	other := newTestChain(t, 2)
+	other.config.GenesisAccounts = append([]GenesisAccount{}, c.config.GenesisAccounts...)
+	other.config.GenesisAccounts[1].Balance, _ = NUSA(100).Add(NewAmount(1))
+	a, _ := c.cm.GetBlockByHeight(0)
+	b, _ := other.fork(nil).cm.GetBlockByHeight(0)
+	if a.Header.StateRoot == b.Header.StateRoot || a.Hash() == b.Hash() {
+		t.Errorf("genesis root %s and hash %s shared", a.Header.StateRoot, a.Hash())
+	}
+}
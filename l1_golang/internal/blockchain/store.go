@@ -0,0 +1,452 @@
+package blockchain
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"nusa-chain/internal/storage"
+)
+
+// Database schema
+//
+//	"LastBlock"        -> hash of the canonical head
+//	"Finalized"        -> hash of the last finalized block
+//	"ChainConfig"      -> JSON ChainConfig the datadir was initialised with
+//	"h" + height (BE)  -> canonical block hash at height
+//	"b" + hash         -> encoded block (canonical or side branch)
+//	"d" + hash         -> total difficulty up to and including the block
+//	"a" + address      -> encoded AccountState
+//	"u" + hash         -> JSON undo record restoring the parent state of a block
+//	"r" + hash         -> JSON receipts of an executed block
+//	"s" + hash         -> JSON Supply after an executed block
+//	"v" + hash         -> JSON validator set after an executed block
+//	"j" + hash         -> JSON Justification finalizing a block
+//	"l" + tx hash      -> JSON TxLookup of a canonical transaction
+//	"x" + sha256(address) + ^height (BE) + ^index (BE)
+//	                   -> JSON TxLookup of a canonical transaction sent or
+//	                      received by address; inverted so newest sorts first
+var (
+	headBlockKey   = []byte("LastBlock")
+	finalizedKey   = []byte("Finalized")
+	chainConfigKey = []byte("ChainConfig")
+
+	canonicalPrefix = []byte("h")
+	blockPrefix     = []byte("b")
+	tdPrefix        = []byte("d")
+	accountPrefix   = []byte("a")
+	undoPrefix      = []byte("u")
+	receiptsPrefix  = []byte("r")
+	supplyPrefix    = []byte("s")
+	validatorPrefix = []byte("v")
+	justifyPrefix   = []byte("j")
+	txLookupPrefix  = []byte("l")
+	addressTxPrefix = []byte("x")
+)
+
+func canonicalKey(height uint64) []byte {
+	key := make([]byte, len(canonicalPrefix)+8)
+	copy(key, canonicalPrefix)
+	binary.BigEndian.PutUint64(key[len(canonicalPrefix):], height)
+	return key
+}
+
+func blockKey(hash string) []byte {
+	return append(append([]byte{}, blockPrefix...), hash...)
+}
+
+func tdKey(hash string) []byte {
+	return append(append([]byte{}, tdPrefix...), hash...)
+}
+
+func undoKey(hash string) []byte {
+	return append(append([]byte{}, undoPrefix...), hash...)
+}
+
+func receiptsKey(hash string) []byte {
+	return append(append([]byte{}, receiptsPrefix...), hash...)
+}
+
+func supplyKey(hash string) []byte {
+	return append(append([]byte{}, supplyPrefix...), hash...)
+}
+
+func validatorSetKey(hash string) []byte {
+	return append(append([]byte{}, validatorPrefix...), hash...)
+}
+
+func justificationKey(hash string) []byte {
+	return append(append([]byte{}, justifyPrefix...), hash...)
+}
+
+func txLookupKey(txHash string) []byte {
+	return append(append([]byte{}, txLookupPrefix...), txHash...)
+}
+
+// Addresses are hashed so one address is never a key prefix of another
+func addressTxsKey(address string) []byte {
+	hash := sha256.Sum256([]byte(address))
+	return append(append([]byte{}, addressTxPrefix...), hash[:]...)
+}
+
+func addressTxPosition(height, index uint64) []byte {
+	position := make([]byte, 16)
+	binary.BigEndian.PutUint64(position, ^height)
+	binary.BigEndian.PutUint64(position[8:], ^index)
+	return position
+}
+
+func accountKey(address string) []byte {
+	return append(append([]byte{}, accountPrefix...), address...)
+}
+
+// Read canonical head hash, "" if the datadir is empty
+func readHeadHash(db storage.Database) (string, error) {
+	data, err := db.Get(headBlockKey)
+	if err == storage.ErrNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func readChainConfig(db storage.Database) (*ChainConfig, error) {
+	data, err := db.Get(chainConfigKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var config ChainConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("corrupt chain config: %v", err)
+	}
+	return &config, nil
+}
+
+func writeChainConfig(batch storage.Batch, config ChainConfig) error {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	batch.Put(chainConfigKey, data)
+	return nil
+}
+
+func readCanonicalHash(db storage.Database, height uint64) (string, error) {
+	data, err := db.Get(canonicalKey(height))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func readBlock(db storage.Database, hash string) (*Block, error) {
+	data, err := db.Get(blockKey(hash))
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := DecodeBlock(data)
+	if err != nil {
+		return nil, fmt.Errorf("corrupt block %s: %v", hash, err)
+	}
+	return block, nil
+}
+
+// Stage a block body and its total difficulty
+func writeBlock(batch storage.Batch, block *Block, td uint64) error {
+	data, err := EncodeBlock(block)
+	if err != nil {
+		return err
+	}
+
+	hash := block.Hash()
+	batch.Put(blockKey(hash), data)
+
+	tdBytes := make([]byte, 8)
+	binary.BigEndian.PutUint64(tdBytes, td)
+	batch.Put(tdKey(hash), tdBytes)
+	return nil
+}
+
+// Stage canonical height mapping and head pointer for an already written block
+func writeCanonicalHead(batch storage.Batch, block *Block) {
+	hash := block.Hash()
+	batch.Put(canonicalKey(block.Header.Height), []byte(hash))
+	batch.Put(headBlockKey, []byte(hash))
+}
+
+// Load every known block (all branches) with its total difficulty
+func readBlockTree(db storage.Database) (map[string]*Block, map[string]uint64, error) {
+	blocks := make(map[string]*Block)
+	it := db.NewIterator(blockPrefix, nil)
+	defer it.Release()
+	for it.Next() {
+		hash := string(it.Key()[len(blockPrefix):])
+		block, err := DecodeBlock(it.Value())
+		if err != nil {
+			return nil, nil, fmt.Errorf("corrupt block %s: %v", hash, err)
+		}
+		blocks[hash] = block
+	}
+	if err := it.Error(); err != nil {
+		return nil, nil, err
+	}
+
+	tds := make(map[string]uint64)
+	tdIt := db.NewIterator(tdPrefix, nil)
+	defer tdIt.Release()
+	for tdIt.Next() {
+		if len(tdIt.Value()) != 8 {
+			return nil, nil, fmt.Errorf("corrupt total difficulty for %s", tdIt.Key())
+		}
+		tds[string(tdIt.Key()[len(tdPrefix):])] = binary.BigEndian.Uint64(tdIt.Value())
+	}
+	return blocks, tds, tdIt.Error()
+}
+
+// Drop the head block from the canonical chain, making parent the head
+func writeCanonicalRevert(batch storage.Batch, block *Block) {
+	batch.Delete(canonicalKey(block.Header.Height))
+	batch.Put(headBlockKey, []byte(block.Header.PrevHash))
+}
+
+func writeUndo(batch storage.Batch, hash string, undo []accountUndo) error {
+	data, err := json.Marshal(undo)
+	if err != nil {
+		return err
+	}
+	batch.Put(undoKey(hash), data)
+	return nil
+}
+
+func readUndo(db storage.Database, hash string) ([]accountUndo, error) {
+	data, err := db.Get(undoKey(hash))
+	if err != nil {
+		return nil, err
+	}
+
+	var undo []accountUndo
+	if err := json.Unmarshal(data, &undo); err != nil {
+		return nil, fmt.Errorf("corrupt undo record %s: %v", hash, err)
+	}
+	return undo, nil
+}
+
+func writeReceipts(batch storage.Batch, hash string, receipts []*Receipt) error {
+	data, err := json.Marshal(receipts)
+	if err != nil {
+		return err
+	}
+	batch.Put(receiptsKey(hash), data)
+	return nil
+}
+
+func readReceipts(db storage.Database, hash string) ([]*Receipt, error) {
+	data, err := db.Get(receiptsKey(hash))
+	if err != nil {
+		return nil, err
+	}
+
+	var receipts []*Receipt
+	if err := json.Unmarshal(data, &receipts); err != nil {
+		return nil, fmt.Errorf("corrupt receipts %s: %v", hash, err)
+	}
+	return receipts, nil
+}
+
+func writeSupply(batch storage.Batch, hash string, supply *Supply) error {
+	data, err := json.Marshal(supply)
+	if err != nil {
+		return err
+	}
+	batch.Put(supplyKey(hash), data)
+	return nil
+}
+
+func readSupply(db storage.Database, hash string) (*Supply, error) {
+	data, err := db.Get(supplyKey(hash))
+	if err != nil {
+		return nil, err
+	}
+
+	var supply Supply
+	if err := json.Unmarshal(data, &supply); err != nil {
+		return nil, fmt.Errorf("corrupt supply record %s: %v", hash, err)
+	}
+	return &supply, nil
+}
+
+func writeValidatorSet(batch storage.Batch, hash string, validators []ValidatorInfo) error {
+	data, err := json.Marshal(validators)
+	if err != nil {
+		return err
+	}
+	batch.Put(validatorSetKey(hash), data)
+	return nil
+}
+
+func readValidatorSet(db storage.Database, hash string) ([]ValidatorInfo, error) {
+	data, err := db.Get(validatorSetKey(hash))
+	if err != nil {
+		return nil, err
+	}
+
+	var validators []ValidatorInfo
+	if err := json.Unmarshal(data, &validators); err != nil {
+		return nil, fmt.Errorf("corrupt validator set %s: %v", hash, err)
+	}
+	return validators, nil
+}
+
+// Stage a justification and make its block the finalized one
+func writeJustification(batch storage.Batch, justification *Justification) error {
+	data, err := json.Marshal(justification)
+	if err != nil {
+		return err
+	}
+	batch.Put(justificationKey(justification.BlockHash), data)
+	batch.Put(finalizedKey, []byte(justification.BlockHash))
+	return nil
+}
+
+func readJustification(db storage.Database, hash string) (*Justification, error) {
+	data, err := db.Get(justificationKey(hash))
+	if err != nil {
+		return nil, err
+	}
+
+	var justification Justification
+	if err := json.Unmarshal(data, &justification); err != nil {
+		return nil, fmt.Errorf("corrupt justification %s: %v", hash, err)
+	}
+	return &justification, nil
+}
+
+// Read the last finalized block hash, "" before the first justification
+func readFinalizedHash(db storage.Database) (string, error) {
+	data, err := db.Get(finalizedKey)
+	if err == storage.ErrNotFound {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// Index every transaction of a canonical block by hash and by the
+// addresses it touches
+func writeTxIndexes(batch storage.Batch, block *Block) {
+	hash := block.Hash()
+	for i, tx := range block.Transactions {
+		data, _ := json.Marshal(TxLookup{
+			TxHash:      tx.Hash,
+			BlockHash:   hash,
+			BlockHeight: block.Header.Height,
+			Index:       uint64(i),
+		})
+		batch.Put(txLookupKey(tx.Hash), data)
+
+		position := addressTxPosition(block.Header.Height, uint64(i))
+		batch.Put(append(addressTxsKey(tx.From), position...), data)
+		if indexesRecipient(tx) {
+			batch.Put(append(addressTxsKey(tx.To), position...), data)
+		}
+	}
+}
+
+func deleteTxIndexes(batch storage.Batch, block *Block) {
+	for i, tx := range block.Transactions {
+		batch.Delete(txLookupKey(tx.Hash))
+
+		position := addressTxPosition(block.Header.Height, uint64(i))
+		batch.Delete(append(addressTxsKey(tx.From), position...))
+		if indexesRecipient(tx) {
+			batch.Delete(append(addressTxsKey(tx.To), position...))
+		}
+	}
+}
+
+// Stake, registration and other types without a recipient only enter the
+// sender's history
+func indexesRecipient(tx Transaction) bool {
+	return tx.To != "" && tx.To != tx.From
+}
+
+func readTxLookup(db storage.Database, txHash string) (*TxLookup, error) {
+	data, err := db.Get(txLookupKey(txHash))
+	if err != nil {
+		return nil, err
+	}
+
+	var lookup TxLookup
+	if err := json.Unmarshal(data, &lookup); err != nil {
+		return nil, fmt.Errorf("corrupt tx lookup %s: %v", txHash, err)
+	}
+	return &lookup, nil
+}
+
+// Read up to limit history entries of address starting at cursor, a hex
+// position ("" for the newest). The returned cursor is the next entry's
+// position, or "" when there are no more.
+func readAddressTxs(db storage.Database, address, cursor string, limit int) ([]TxLookup, string, error) {
+	start, err := hex.DecodeString(cursor)
+	if err != nil || (len(start) != 0 && len(start) != 16) {
+		return nil, "", fmt.Errorf("invalid cursor %q", cursor)
+	}
+
+	prefix := addressTxsKey(address)
+	it := db.NewIterator(prefix, start)
+	defer it.Release()
+
+	lookups := []TxLookup{}
+	for it.Next() {
+		if len(lookups) == limit {
+			return lookups, hex.EncodeToString(it.Key()[len(prefix):]), nil
+		}
+		var lookup TxLookup
+		if err := json.Unmarshal(it.Value(), &lookup); err != nil {
+			return nil, "", fmt.Errorf("corrupt address index of %s: %v", address, err)
+		}
+		lookups = append(lookups, lookup)
+	}
+	return lookups, "", it.Error()
+}
+
+func writeAccount(batch storage.Batch, address string, state AccountState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	batch.Put(accountKey(address), data)
+	return nil
+}
+
+func deleteAccount(batch storage.Batch, address string) {
+	batch.Delete(accountKey(address))
+}
+
+// Load the full account map
+func readAccounts(db storage.Database) (map[string]AccountState, error) {
+	accounts := make(map[string]AccountState)
+
+	it := db.NewIterator(accountPrefix, nil)
+	defer it.Release()
+
+	for it.Next() {
+		address := string(it.Key()[len(accountPrefix):])
+
+		var state AccountState
+		if err := json.Unmarshal(it.Value(), &state); err != nil {
+			return nil, fmt.Errorf("corrupt account %s: %v", address, err)
+		}
+		accounts[address] = state
+	}
+
+	return accounts, it.Error()
+}
@@ -0,0 +1,122 @@
+package blockchain
+
+import (
+	"reflect"
+	"testing"
+
+	"nusa-chain/internal/storage"
+)
+
+func TestChainReopens(t *testing.T) {
+	dir := t.TempDir()
+	db, err := storage.Open("leveldb", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newTestChain(t, 2).fork(db)
+	recipient := keyAddress(newTestKey(t))
+	c.mine(c.transfer(0, 0, recipient, NUSA(5)))
+	c.mine(c.transfer(1, 0, recipient, NUSA(7)))
+	head := c.cm.GetLatestBlock()
+	before := c.state()
+	if err := c.cm.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = storage.Open("leveldb", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reopened := c.fork(db)
+	defer reopened.cm.Close()
+	reopened.requireState(before)
+	for height := uint64(0); height <= head.Header.Height; height++ {
+		want, _ := c.cm.GetBlockByHeight(height)
+		block, err := reopened.cm.GetBlockByHeight(height)
+		if err != nil {
+			t.Fatalf("block #%d after reopening: %v", height, err)
+		}
+		if block.Hash() != want.Hash() {
+			t.Fatalf("block #%d is %s after reopening, want %s", height, block.Hash(), want.Hash())
+		}
+	}
+	if balance := reopened.cm.GetBalance(recipient); balance != NUSA(12) {
+		t.Errorf("recipient balance %s after reopening, want %s", balance, NUSA(12))
+	}
+
+	// The reopened node builds on the stored head, not a fresh genesis
+	next := reopened.mine(reopened.transfer(0, 1, recipient, NUSA(1)))
+	if next.Header.PrevHash != head.Hash() {
+		t.Errorf("block #%d builds on %s, want %s", next.Header.Height, next.Header.PrevHash, head.Hash())
+	}
+}
+
+func TestTxIndexes(t *testing.T) {
+	a := newTestChain(t, 2)
+	b := a.fork(nil)
+	sent := a.transfer(0, 0, a.addrs[1], NUSA(1))
+	a.mine(sent)
+	received := a.transfer(1, 0, a.addrs[0], NUSA(2))
+	stake := a.typed(1, 1, TxTypeStake, "", NUSA(3))
+	a2 := a.mine(received, stake)
+
+	tx, lookup, err := a.cm.GetTransaction(stake.Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tx.Hash != stake.Hash || lookup.BlockHash != a2.Hash() || lookup.BlockHeight != 2 || lookup.Index != 1 {
+		t.Errorf("lookup %+v for the stake in %s", lookup, a2.Hash())
+	}
+	if _, _, err := a.cm.GetTransaction(testHashes(1)[0]); err == nil {
+		t.Error("unknown transaction found")
+	}
+
+	// Newest first, across pages
+	var history []string
+	cursor := ""
+	for page := 0; ; page++ {
+		lookups, next, err := a.cm.GetAddressTxs(a.addrs[1], cursor, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(lookups) > 2 || page > 2 {
+			t.Fatalf("page %d has %d entries", page, len(lookups))
+		}
+		for _, lookup := range lookups {
+			history = append(history, lookup.TxHash)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if want := []string{stake.Hash, received.Hash, sent.Hash}; !reflect.DeepEqual(history, want) {
+		t.Errorf("history %v, want %v", history, want)
+	}
+	if _, _, err := a.cm.GetAddressTxs(a.addrs[1], "", 0); err == nil {
+		t.Error("limit 0 accepted")
+	}
+	if lookups, _, err := a.cm.GetAddressTxs("", "", 10); err != nil || len(lookups) != 0 {
+		t.Errorf("transactions without a recipient indexed under \"\": %v (%v)", lookups, err)
+	}
+
+	// A longer branch orphans a1 and a2 and their entries with them
+	for i := 0; i < 3; i++ {
+		if err := a.cm.AddBlock(b.mine()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if head := a.cm.GetLatestBlock(); head.Hash() == a2.Hash() || head.Header.Height != 3 {
+		t.Fatalf("head #%d did not reorg", head.Header.Height)
+	}
+	for _, tx := range []Transaction{sent, received, stake} {
+		if _, _, err := a.cm.GetTransaction(tx.Hash); err == nil {
+			t.Errorf("orphaned %s still indexed", tx.Hash)
+		}
+	}
+	for _, address := range a.addrs {
+		if lookups, _, err := a.cm.GetAddressTxs(address, "", 10); err != nil || len(lookups) != 0 {
+			t.Errorf("%s history %v (%v) after the reorg", address, lookups, err)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package blockchain
+
+import (
+	"fmt"
+)
+
+// Supply is the NUSA accounting after a block, in wei. Every account's
+// balance, stake and unbonding stake, with the delegations and delegator
+// rewards held by validators, add up to Total, and Total is always
+// Genesis + Minted - Burned.
+type Supply struct {
+	Height      uint64 `json:"height"`
+	Genesis     Amount `json:"genesis"` // allocated in the genesis state
+	Minted      Amount `json:"minted"`  // block rewards since genesis
+	Burned      Amount `json:"burned"`  // base fees since genesis
+	Total       Amount `json:"total"`
+	Staked      Amount `json:"staked"` // own and delegated
+	Unbonding   Amount `json:"unbonding"`
+	Circulating Amount `json:"circulating"` // Total - Staked - Unbonding
+}
+
+// GetSupply returns the supply after the head block
+func (cm *ChainManager) GetSupply() Supply {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+	return *cm.supply
+}
+
+// GetSupplyAt returns the supply after the canonical block at height
+func (cm *ChainManager) GetSupplyAt(height uint64) (*Supply, error) {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+
+	if height >= uint64(len(cm.Chain)) {
+		return nil, fmt.Errorf("block %d not found", height)
+	}
+	supply, err := readSupply(cm.db, cm.Chain[height].Hash())
+	if err != nil {
+		return nil, fmt.Errorf("no supply record for block %d: %v", height, err)
+	}
+	return supply, nil
+}
+
+// genesisSupply counts everything allocated at genesis
+func genesisSupply(state map[string]AccountState) *Supply {
+	supply := &Supply{}
+	for _, account := range state {
+		supply.Total, _ = supply.Total.Add(accountTotal(account))
+		supply.Staked, _ = supply.Staked.Add(bondedStake(account))
+		supply.Unbonding, _ = supply.Unbonding.Add(unbondingTotal(account))
+	}
+	supply.Genesis = supply.Total
+	supply.Circulating = circulating(supply)
+	return supply
+}
+
+// nextSupply books an executed block on top of the head supply and checks
+// the invariant: the accounts the block touched must have changed in total
+// by exactly the reward minted minus the base fees burned
+func (cm *ChainManager) nextSupply(state *stateDB, block *Block) (*Supply, error) {
+	parent := cm.supply
+	burned, err := block.Header.BaseFee.MulUint64(block.Header.GasUsed)
+	if err != nil {
+		return nil, fmt.Errorf("burned fees: %v", err)
+	}
+
+	next := &Supply{Height: block.Header.Height, Genesis: parent.Genesis}
+	if next.Minted, err = parent.Minted.Add(block.Header.Reward); err != nil {
+		return nil, fmt.Errorf("minted supply: %v", err)
+	}
+	if next.Burned, err = parent.Burned.Add(burned); err != nil {
+		return nil, fmt.Errorf("burned supply: %v", err)
+	}
+	total, err := next.Genesis.Add(next.Minted)
+	if err == nil {
+		next.Total, err = total.Sub(next.Burned)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("total supply: %v", err)
+	}
+
+	// Swap the touched accounts' old holdings for their new ones
+	held, staked, unbonding := parent.Total, parent.Staked, parent.Unbonding
+	for address, account := range state.dirty {
+		old := state.base[address]
+		if held, err = held.Sub(accountTotal(old)); err == nil {
+			held, err = held.Add(accountTotal(account))
+		}
+		if err == nil {
+			if staked, err = staked.Sub(bondedStake(old)); err == nil {
+				staked, err = staked.Add(bondedStake(account))
+			}
+		}
+		if err == nil {
+			if unbonding, err = unbonding.Sub(unbondingTotal(old)); err == nil {
+				unbonding, err = unbonding.Add(unbondingTotal(account))
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("supply invariant violated at %s: %v", address, err)
+		}
+	}
+	if held != next.Total {
+		return nil, fmt.Errorf("supply invariant violated: accounts hold %s, expected %s (minted %s, burned %s)", held, next.Total, block.Header.Reward, burned)
+	}
+
+	next.Staked, next.Unbonding = staked, unbonding
+	next.Circulating = circulating(next)
+	return next, nil
+}
+
+// validateBlockReward holds a block to the configured block reward; with a
+// zero BlockReward blocks mint nothing
+func (cm *ChainManager) validateBlockReward(block *Block) error {
+	if block.Header.Reward.Cmp(cm.config.BlockReward) > 0 {
+		return fmt.Errorf("reward %s exceeds the block reward %s", block.Header.Reward, cm.config.BlockReward)
+	}
+	return nil
+}
+
+func accountTotal(account AccountState) Amount {
+	total, _ := account.Balance.Add(account.Stake)
+	total, _ = total.Add(unbondingTotal(account))
+	total, _ = total.Add(delegationsTotal(account))
+	return total
+}
+
+func circulating(supply *Supply) Amount {
+	locked, _ := supply.Staked.Add(supply.Unbonding)
+	free, _ := supply.Total.Sub(locked)
+	return free
+}
+
+// loadSupply reads the supply record of head. Datadirs written before
+// supply tracking start counting from the current state.
+func (cm *ChainManager) loadSupply(head *Block) {
+	supply, err := readSupply(cm.db, head.Hash())
+	if err != nil {
+		fmt.Printf("⚠️  No supply record for block #%d, counting from the current state\n", head.Header.Height)
+		supply = genesisSupply(cm.State)
+		supply.Height = head.Header.Height
+	}
+	cm.supply = supply
+}
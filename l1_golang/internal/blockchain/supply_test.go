@@ -0,0 +1,102 @@
+package blockchain
+
+import (
+	"strings"
+	"testing"
+)
+
+// heldSupply adds up everything the accounts hold
+func heldSupply(state map[string]AccountState) (total, staked, unbonding Amount) {
+	for _, account := range state {
+		total, _ = total.Add(accountTotal(account))
+		staked, _ = staked.Add(bondedStake(account))
+		unbonding, _ = unbonding.Add(unbondingTotal(account))
+	}
+	return total, staked, unbonding
+}
+
+func TestSupplyTracksBlocks(t *testing.T) {
+	c := newTestChain(t, 2)
+	c.config.UnbondingPeriod = 100
+	c.config.MinGasPrice = NewAmount(1000000000)
+	c = c.fork(nil)
+	recipient := keyAddress(newTestKey(t))
+	genesis := c.cm.GetSupply()
+	if total, _, _ := heldSupply(c.cm.State); genesis.Genesis != NUSA(200) || genesis.Total != total {
+		t.Fatalf("genesis supply %+v", genesis)
+	}
+
+	tests := []struct {
+		name      string
+		txs       func() []Transaction
+		staked    Amount // after the block
+		unbonding Amount
+	}{
+		{"empty block", func() []Transaction { return nil }, Amount{}, Amount{}},
+		{"transfer", func() []Transaction { return []Transaction{c.transfer(0, 0, recipient, NUSA(5))} }, Amount{}, Amount{}},
+		{"stake", func() []Transaction { return []Transaction{c.typed(1, 0, TxTypeStake, "", NUSA(30))} }, NUSA(30), Amount{}},
+		{"unstake", func() []Transaction { return []Transaction{c.typed(1, 1, TxTypeUnstake, "", NUSA(12))} }, NUSA(18), NUSA(12)},
+	}
+	minted, burned := Amount{}, Amount{}
+	for _, test := range tests {
+		block := c.mine(test.txs()...)
+		fee, _ := block.Header.BaseFee.MulUint64(block.Header.GasUsed)
+		burned, _ = burned.Add(fee)
+		minted, _ = minted.Add(c.config.BlockReward)
+		if test.name == "transfer" && fee.IsZero() {
+			t.Fatal("transfer burned no base fee")
+		}
+
+		supply := c.cm.GetSupply()
+		total, staked, unbonding := heldSupply(c.cm.State)
+		want, _ := genesis.Genesis.Add(minted)
+		want, _ = want.Sub(burned)
+		if supply.Minted != minted || supply.Burned != burned || supply.Total != want || total != want {
+			t.Errorf("%s: minted %s burned %s total %s, accounts hold %s; want %s %s %s", test.name,
+				supply.Minted, supply.Burned, supply.Total, total, minted, burned, want)
+		}
+		if supply.Staked != test.staked || staked != test.staked || supply.Unbonding != test.unbonding || unbonding != test.unbonding {
+			t.Errorf("%s: staked %s unbonding %s, want %s %s", test.name, supply.Staked, supply.Unbonding, test.staked, test.unbonding)
+		}
+		locked, _ := test.staked.Add(test.unbonding)
+		if circulating, _ := want.Sub(locked); supply.Circulating != circulating {
+			t.Errorf("%s: circulating %s, want %s", test.name, supply.Circulating, circulating)
+		}
+		if stored, err := c.cm.GetSupplyAt(block.Header.Height); err != nil || *stored != supply {
+			t.Errorf("%s: stored supply %+v (%v), head has %+v", test.name, stored, err, supply)
+		}
+	}
+}
+
+func TestSupplyInvariant(t *testing.T) {
+	c := newTestChain(t, 1)
+	head := c.cm.GetLatestBlock()
+
+	overpaid := c.build()
+	overpaid.Header.Reward, _ = c.config.BlockReward.Add(NewAmount(1))
+	if err := overpaid.Sign(c.producer); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.cm.AddBlock(overpaid); err == nil || !strings.Contains(err.Error(), "exceeds the block reward") {
+		t.Errorf("block minting above the reward: error %v", err)
+	}
+	if c.cm.GetLatestBlock() != head {
+		t.Fatal("head moved")
+	}
+
+	// Execution that credits more than the reward breaks the invariant
+	block := c.build()
+	state := c.cm.newStateDB()
+	if _, err := c.cm.executeBlock(state, block); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.cm.nextSupply(state, block); err != nil {
+		t.Fatalf("honest block: %v", err)
+	}
+	account, _ := state.getAccount(c.addrs[0])
+	account.Balance, _ = account.Balance.Add(NewAmount(1))
+	state.setAccount(c.addrs[0], account)
+	if _, err := c.cm.nextSupply(state, block); err == nil || !strings.Contains(err.Error(), "invariant") {
+		t.Errorf("one wei from nowhere: error %v", err)
+	}
+}
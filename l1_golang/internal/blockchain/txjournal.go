@@ -0,0 +1,144 @@
+package blockchain
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// txJournal keeps the mempool on disk so pending transactions survive a
+// restart. Accepted transactions are appended as they arrive; the file is
+// rewritten from the live pool once stale entries outnumber live ones.
+// Each record is a 4-byte big-endian length followed by EncodeTransaction.
+type txJournal struct {
+	path    string
+	writer  *os.File
+	entries int
+}
+
+// Upper bound on one journaled transaction, guarding against corrupt
+// lengths; data costs gas, so no transaction that fits a block comes close
+const maxJournalTxSize = 4 * 1024 * 1024
+
+func newTxJournal(path string) *txJournal {
+	return &txJournal{path: path}
+}
+
+// load feeds every journaled transaction to add and reports how many were
+// accepted and dropped. A record cut short by a crash, or with a length no
+// transaction has, ends the journal.
+func (j *txJournal) load(add func(Transaction) error) (int, int, error) {
+	file, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	loaded, dropped := 0, 0
+	for {
+		var size uint32
+		if err := binary.Read(reader, binary.BigEndian, &size); err != nil {
+			if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+				break
+			}
+			return loaded, dropped, err
+		}
+		if size > maxJournalTxSize {
+			break
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			break
+		}
+
+		tx, err := DecodeTransaction(data)
+		if err != nil || add(*tx) != nil {
+			dropped++
+			continue
+		}
+		loaded++
+	}
+	return loaded, dropped, nil
+}
+
+// insert appends one transaction to the journal
+func (j *txJournal) insert(tx *Transaction) error {
+	if j.writer == nil {
+		return fmt.Errorf("transaction journal not open")
+	}
+	if err := writeJournalRecord(j.writer, tx); err != nil {
+		return err
+	}
+	j.entries++
+	return nil
+}
+
+// rotate replaces the journal with txs and reopens it for appending
+func (j *txJournal) rotate(txs []Transaction) error {
+	if j.writer != nil {
+		if err := j.writer.Close(); err != nil {
+			return err
+		}
+		j.writer = nil
+	}
+
+	tmp, err := os.OpenFile(j.path+".new", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	for i := range txs {
+		if err := writeJournalRecord(tmp, &txs[i]); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	tmp.Close()
+	if err := os.Rename(j.path+".new", j.path); err != nil {
+		return err
+	}
+
+	writer, err := os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	j.writer = writer
+	j.entries = len(txs)
+	return nil
+}
+
+// Stale entries are those already mined, replaced or dropped
+func (j *txJournal) needsRotate(live int) bool {
+	return j.entries > 2*live+64
+}
+
+func (j *txJournal) close() error {
+	if j.writer == nil {
+		return nil
+	}
+	err := j.writer.Close()
+	j.writer = nil
+	return err
+}
+
+func writeJournalRecord(w io.Writer, tx *Transaction) error {
+	data, err := EncodeTransaction(tx)
+	if err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.BigEndian, uint32(len(data))); err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
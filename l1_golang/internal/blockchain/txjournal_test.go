@@ -0,0 +1,186 @@
+package blockchain
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"nusa-chain/internal/storage"
+)
+
+// pooled reports which of txs the pool holds
+func pooled(cm *ChainManager, txs []Transaction) []bool {
+	held := make([]bool, len(txs))
+	for i, tx := range txs {
+		_, held[i] = cm.txPool.all[tx.Hash]
+	}
+	return held
+}
+
+func TestTxJournalReload(t *testing.T) {
+	db := storage.NewMemoryDB()
+	path := filepath.Join(t.TempDir(), "transactions.rlp")
+	c := newTestChain(t, 3).fork(db)
+	if err := c.cm.OpenTxJournal(path); err != nil {
+		t.Fatal(err)
+	}
+	recipient := keyAddress(newTestKey(t))
+	txs := []Transaction{
+		c.transfer(0, 0, recipient, NUSA(1)),
+		c.transfer(0, 1, recipient, NUSA(1)),
+		c.transfer(1, 0, recipient, NUSA(1)),
+		c.transfer(2, 0, recipient, NUSA(1)),
+		c.transfer(2, 1, recipient, NUSA(50)),
+	}
+	for _, tx := range txs {
+		if err := c.cm.AddTransaction(tx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Another node's block spends the nonces of accounts 1 and 2 and leaves
+	// account 2 too little for its next transfer. The node stops without
+	// closing, so the journal is all it has.
+	other := c.fork(db)
+	other.mine(
+		c.transfer(1, 0, recipient, NUSA(2)),
+		c.transfer(2, 0, recipient, NUSA(99)),
+	)
+
+	reopened := c.fork(db)
+	if err := reopened.cm.OpenTxJournal(path); err != nil {
+		t.Fatal(err)
+	}
+	want := []bool{true, true, false, false, false}
+	for i, held := range pooled(reopened.cm, txs) {
+		if held != want[i] {
+			t.Errorf("transaction %d pooled %v after reload, want %v", i, held, want[i])
+		}
+	}
+	if err := reopened.cm.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Closing compacted the journal down to the two live transactions
+	loaded, dropped, err := newTxJournal(path).load(func(Transaction) error { return nil })
+	if err != nil || loaded != 2 || dropped != 0 {
+		t.Errorf("journal holds %d records, %d unreadable: %v", loaded, dropped, err)
+	}
+}
+
+func TestTxJournalDamagedTail(t *testing.T) {
+	c := newTestChain(t, 1)
+	recipient := keyAddress(newTestKey(t))
+	var txs []Transaction
+	for nonce := uint64(0); nonce < 3; nonce++ {
+		txs = append(txs, c.transfer(0, nonce, recipient, NUSA(1)))
+	}
+
+	tests := []struct {
+		name   string
+		damage func(path string) error
+	}{
+		{"last record cut short", func(path string) error {
+			info, err := os.Stat(path)
+			if err != nil {
+				return err
+			}
+			return os.Truncate(path, info.Size()-3)
+		}},
+		{"length no transaction has", func(path string) error {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			// Rewrite the last record's length, as a torn write could leave it
+			binary.BigEndian.PutUint32(data[journalRecordStart(t, data, 2):], maxJournalTxSize+1)
+			return os.WriteFile(path, data, 0644)
+		}},
+	}
+	for _, test := range tests {
+		path := filepath.Join(t.TempDir(), "transactions.rlp")
+		journal := newTxJournal(path)
+		if err := journal.rotate(txs); err != nil {
+			t.Fatal(err)
+		}
+		journal.close()
+		if err := test.damage(path); err != nil {
+			t.Fatal(err)
+		}
+
+		node := c.fork(nil)
+		if err := node.cm.OpenTxJournal(path); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		want := []bool{true, true, false}
+		for i, held := range pooled(node.cm, txs) {
+			if held != want[i] {
+				t.Errorf("%s: transaction %d pooled %v, want %v", test.name, i, held, want[i])
+			}
+		}
+	}
+}
+
+// journalRecordStart returns the offset of record n in a journal
+func journalRecordStart(t *testing.T, data []byte, n int) int {
+	offset := 0
+	for i := 0; i < n; i++ {
+		if offset+4 > len(data) {
+			t.Fatalf("journal has fewer than %d records", n)
+		}
+		offset += 4 + int(binary.BigEndian.Uint32(data[offset:]))
+	}
+	return offset
+}
+
+func TestTxJournalRotate(t *testing.T) {
+	c := newTestChain(t, 1)
+	recipient := keyAddress(newTestKey(t))
+	var txs []Transaction
+	for nonce := uint64(0); nonce < 100; nonce++ {
+		txs = append(txs, c.transfer(0, nonce, recipient, NUSA(1)))
+	}
+
+	path := filepath.Join(t.TempDir(), "transactions.rlp")
+	journal := newTxJournal(path)
+	if err := journal.rotate(nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := range txs {
+		if err := journal.insert(&txs[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	live := txs[90:]
+	if !journal.needsRotate(len(live)) {
+		t.Fatalf("%d entries for %d live transactions not rotated", journal.entries, len(live))
+	}
+	if err := journal.rotate(live); err != nil {
+		t.Fatal(err)
+	}
+	if journal.entries != len(live) || journal.needsRotate(len(live)) {
+		t.Errorf("%d entries after rotating to %d live transactions", journal.entries, len(live))
+	}
+
+	// Appends after the rotation land behind the live transactions
+	extra := c.transfer(0, 100, recipient, NUSA(1))
+	if err := journal.insert(&extra); err != nil {
+		t.Fatal(err)
+	}
+	journal.close()
+
+	var nonces []uint64
+	if _, _, err := journal.load(func(tx Transaction) error {
+		nonces = append(nonces, tx.Nonce)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(nonces) != 11 || nonces[0] != 90 || nonces[10] != 100 {
+		t.Errorf("journal holds nonces %v, want 90 to 100", nonces)
+	}
+	if _, err := os.Stat(path + ".new"); !os.IsNotExist(err) {
+		t.Errorf("rotation left %s.new behind", path)
+	}
+}
@@ -0,0 +1,325 @@
+package blockchain
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+var (
+	ErrAlreadyKnown        = errors.New("transaction already in mempool")
+	ErrNonceTooLow         = errors.New("nonce too low")
+	ErrInsufficientFunds   = errors.New("insufficient funds for value and gas")
+	ErrReplaceUnderpriced  = errors.New("replacement transaction underpriced")
+	ErrAccountLimitReached = errors.New("too many transactions from sender")
+	ErrTxPoolFull          = errors.New("mempool full and fee too low to evict")
+)
+
+type TxPoolConfig struct {
+	GlobalSlots  int           // transactions kept across all senders
+	AccountSlots int           // transactions kept per sender
+	PriceBump    uint64        // % fee increase needed to replace a transaction
+	Lifetime     time.Duration // transactions older than this are dropped
+}
+
+var DefaultTxPoolConfig = TxPoolConfig{
+	GlobalSlots:  4096,
+	AccountSlots: 64,
+	PriceBump:    10,
+	Lifetime:     3 * time.Hour,
+}
+
+// TxPool holds transactions waiting for a block. Per sender, pending
+// holds the transactions executable in nonce order from the account's
+// current nonce; queued holds the ones behind a nonce gap. The pool is
+// guarded by ChainManager.mutex.
+type TxPool struct {
+	config  TxPoolConfig
+	all     map[string]*poolTx
+	pending map[string]map[uint64]*poolTx // sender -> nonce -> tx
+	queued  map[string]map[uint64]*poolTx
+}
+
+type poolTx struct {
+	tx    Transaction
+	added time.Time
+}
+
+func NewTxPool(config TxPoolConfig) *TxPool {
+	return &TxPool{
+		config:  config,
+		all:     make(map[string]*poolTx),
+		pending: make(map[string]map[uint64]*poolTx),
+		queued:  make(map[string]map[uint64]*poolTx),
+	}
+}
+
+// add validates tx against the head state and inserts it, replacing a
+// cheaper transaction with the same sender and nonce
+func (p *TxPool) add(tx Transaction, state map[string]AccountState) error {
+	if _, exists := p.all[tx.Hash]; exists {
+		return ErrAlreadyKnown
+	}
+
+	account := state[tx.From]
+	if tx.Nonce < account.Nonce {
+		return fmt.Errorf("%w: account at %d, got %d", ErrNonceTooLow, account.Nonce, tx.Nonce)
+	}
+
+	// Replacing an existing nonce needs a fee bump on both caps
+	old := p.lookup(tx.From, tx.Nonce)
+	if old != nil {
+		if !p.outbids(&tx, &old.tx) {
+			return fmt.Errorf("%w: need %d%% more than %s", ErrReplaceUnderpriced, p.config.PriceBump, old.tx.FeeCap())
+		}
+	} else if len(p.pending[tx.From])+len(p.queued[tx.From]) >= p.config.AccountSlots {
+		return ErrAccountLimitReached
+	}
+
+	// The sender must afford this and every earlier transaction it has pooled
+	cost, err := txCost(&tx)
+	if err != nil {
+		return err
+	}
+	for _, other := range p.senderTxs(tx.From) {
+		if other.tx.Nonce >= tx.Nonce {
+			continue
+		}
+		otherCost, err := txCost(&other.tx)
+		if err == nil {
+			cost, err = cost.Add(otherCost)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if account.Balance.Cmp(cost) < 0 {
+		return ErrInsufficientFunds
+	}
+
+	if old == nil && len(p.all) >= p.config.GlobalSlots {
+		victim := p.cheapestTail(tx.From)
+		if victim == nil || tx.FeeCap().Cmp(victim.tx.FeeCap()) <= 0 {
+			return ErrTxPoolFull
+		}
+		p.remove(&victim.tx)
+	}
+
+	if old != nil {
+		p.remove(&old.tx)
+	}
+	p.insert(&poolTx{tx: tx, added: time.Now()}, account.Nonce)
+	return nil
+}
+
+// reset re-sorts the pool after the head changed: stale nonces and
+// expired transactions are dropped, and each sender's transactions are
+// split again into pending and queued
+func (p *TxPool) reset(state map[string]AccountState) {
+	senders := make(map[string]bool)
+	for sender := range p.pending {
+		senders[sender] = true
+	}
+	for sender := range p.queued {
+		senders[sender] = true
+	}
+
+	now := time.Now()
+	for sender := range senders {
+		txs := p.senderTxs(sender)
+		delete(p.pending, sender)
+		delete(p.queued, sender)
+
+		nonce := state[sender].Nonce
+		for _, ptx := range txs {
+			if ptx.tx.Nonce < nonce || now.Sub(ptx.added) > p.config.Lifetime {
+				delete(p.all, ptx.tx.Hash)
+				continue
+			}
+			p.insert(ptx, nonce)
+		}
+	}
+}
+
+// pendingTxs returns a copy of the executable transactions, each sender's
+// in nonce order, interleaved so higher fee caps come first
+func (p *TxPool) pendingTxs() []Transaction {
+	heads := make(map[string][]*poolTx)
+	for sender := range p.pending {
+		heads[sender] = sortedByNonce(p.pending[sender])
+	}
+
+	txs := make([]Transaction, 0, len(p.all))
+	for len(heads) > 0 {
+		var best string
+		for sender, list := range heads {
+			if best == "" || list[0].tx.FeeCap().Cmp(heads[best][0].tx.FeeCap()) > 0 ||
+				(list[0].tx.FeeCap() == heads[best][0].tx.FeeCap() && sender < best) {
+				best = sender
+			}
+		}
+		txs = append(txs, heads[best][0].tx)
+		if heads[best] = heads[best][1:]; len(heads[best]) == 0 {
+			delete(heads, best)
+		}
+	}
+	return txs
+}
+
+// allTxs returns every pooled transaction, pending and queued, ordered by
+// sender and nonce so a reload rebuilds the same queues
+func (p *TxPool) allTxs() []Transaction {
+	txs := make([]Transaction, 0, len(p.all))
+	for _, ptx := range p.all {
+		txs = append(txs, ptx.tx)
+	}
+	sort.Slice(txs, func(i, j int) bool {
+		if txs[i].From != txs[j].From {
+			return txs[i].From < txs[j].From
+		}
+		return txs[i].Nonce < txs[j].Nonce
+	})
+	return txs
+}
+
+// stats returns the number of pending and queued transactions
+func (p *TxPool) stats() (int, int) {
+	pending, queued := 0, 0
+	for _, list := range p.pending {
+		pending += len(list)
+	}
+	for _, list := range p.queued {
+		queued += len(list)
+	}
+	return pending, queued
+}
+
+// Place ptx into pending if every nonce from the account's nonce up to
+// it is present, otherwise into queued; then promote queued successors
+func (p *TxPool) insert(ptx *poolTx, accountNonce uint64) {
+	sender := ptx.tx.From
+	p.all[ptx.tx.Hash] = ptx
+
+	if ptx.tx.Nonce != accountNonce+uint64(len(p.pending[sender])) {
+		putTx(p.queued, ptx)
+		return
+	}
+	putTx(p.pending, ptx)
+
+	// Close the gap: queued transactions that now follow on become pending
+	for nonce := accountNonce + uint64(len(p.pending[sender])); ; nonce++ {
+		queued, exists := p.queued[sender][nonce]
+		if !exists {
+			break
+		}
+		delete(p.queued[sender], nonce)
+		putTx(p.pending, queued)
+	}
+	if len(p.queued[sender]) == 0 {
+		delete(p.queued, sender)
+	}
+}
+
+// remove drops tx; later pending transactions of the sender lose their
+// predecessor and are moved to queued
+func (p *TxPool) remove(tx *Transaction) {
+	delete(p.all, tx.Hash)
+	if list, exists := p.pending[tx.From]; exists {
+		if _, inPending := list[tx.Nonce]; inPending {
+			delete(list, tx.Nonce)
+			for nonce, ptx := range list {
+				if nonce > tx.Nonce {
+					delete(list, nonce)
+					putTx(p.queued, ptx)
+				}
+			}
+			if len(list) == 0 {
+				delete(p.pending, tx.From)
+			}
+			return
+		}
+	}
+	if list, exists := p.queued[tx.From]; exists {
+		delete(list, tx.Nonce)
+		if len(list) == 0 {
+			delete(p.queued, tx.From)
+		}
+	}
+}
+
+func (p *TxPool) lookup(sender string, nonce uint64) *poolTx {
+	if ptx, exists := p.pending[sender][nonce]; exists {
+		return ptx
+	}
+	return p.queued[sender][nonce]
+}
+
+func (p *TxPool) senderTxs(sender string) []*poolTx {
+	txs := sortedByNonce(p.pending[sender])
+	txs = append(txs, sortedByNonce(p.queued[sender])...)
+	sort.Slice(txs, func(i, j int) bool { return txs[i].tx.Nonce < txs[j].tx.Nonce })
+	return txs
+}
+
+// Eviction only takes a sender's highest nonce, so no gap is left behind
+func (p *TxPool) cheapestTail(exclude string) *poolTx {
+	var cheapest *poolTx
+	senders := make(map[string]bool)
+	for sender := range p.pending {
+		senders[sender] = true
+	}
+	for sender := range p.queued {
+		senders[sender] = true
+	}
+	for sender := range senders {
+		if sender == exclude {
+			continue
+		}
+		txs := p.senderTxs(sender)
+		tail := txs[len(txs)-1]
+		// On equal fees the newer transaction goes first
+		cmp := 1
+		if cheapest != nil {
+			cmp = cheapest.tx.FeeCap().Cmp(tail.tx.FeeCap())
+		}
+		if cmp > 0 || (cmp == 0 && tail.added.After(cheapest.added)) {
+			cheapest = tail
+		}
+	}
+	return cheapest
+}
+
+// outbids reports whether tx raises both fee caps of old by PriceBump percent
+func (p *TxPool) outbids(tx, old *Transaction) bool {
+	bump := func(a Amount) Amount {
+		return a.MulDiv(100+p.config.PriceBump, 100)
+	}
+	return tx.FeeCap().Cmp(bump(old.FeeCap())) >= 0 && tx.TipCap().Cmp(bump(old.TipCap())) >= 0
+}
+
+func putTx(lists map[string]map[uint64]*poolTx, ptx *poolTx) {
+	if lists[ptx.tx.From] == nil {
+		lists[ptx.tx.From] = make(map[uint64]*poolTx)
+	}
+	lists[ptx.tx.From][ptx.tx.Nonce] = ptx
+}
+
+func sortedByNonce(list map[uint64]*poolTx) []*poolTx {
+	txs := make([]*poolTx, 0, len(list))
+	for _, ptx := range list {
+		txs = append(txs, ptx)
+	}
+	sort.Slice(txs, func(i, j int) bool { return txs[i].tx.Nonce < txs[j].tx.Nonce })
+	return txs
+}
+
+// Most a transaction can take from its sender's balance: the value it
+// spends plus the full gas limit at the fee cap
+func txCost(tx *Transaction) (Amount, error) {
+	gas, err := tx.FeeCap().MulUint64(tx.GasLimit)
+	if err != nil {
+		return Amount{}, err
+	}
+	return gas.Add(tx.balanceValue())
+}
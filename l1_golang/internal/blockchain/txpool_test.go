@@ -0,0 +1,196 @@
+package blockchain
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// poolTransfer is an unsigned transfer; the pool leaves signatures to
+// ChainManager
+func poolTransfer(from string, nonce, feeCap, tipCap uint64) Transaction {
+	tx := Transaction{
+		ChainID:              1,
+		Nonce:                nonce,
+		From:                 from,
+		To:                   "0x0000000000000000000000000000000000000001",
+		Value:                NewAmount(1),
+		GasLimit:             TxGas,
+		MaxFeePerGas:         NewAmount(feeCap),
+		MaxPriorityFeePerGas: NewAmount(tipCap),
+	}
+	tx.Hash = tx.CalculateHash()
+	return tx
+}
+
+// poolNonces lists the nonces held per sender
+func poolNonces(lists map[string]map[uint64]*poolTx) map[string][]uint64 {
+	nonces := make(map[string][]uint64)
+	for sender, list := range lists {
+		for nonce := range list {
+			nonces[sender] = append(nonces[sender], nonce)
+		}
+		sort.Slice(nonces[sender], func(i, j int) bool { return nonces[sender][i] < nonces[sender][j] })
+	}
+	return nonces
+}
+
+func TestTxPool(t *testing.T) {
+	alice := "0x00000000000000000000000000000000000A11cE"
+	bob := "0x0000000000000000000000000000000000000b0b"
+	carol := "0x00000000000000000000000000000000000CA201"
+	state := map[string]AccountState{
+		alice: {Balance: NUSA(1), Nonce: 2},
+		bob:   {Balance: NUSA(1)},
+		// enough for two transfers at fee cap 100
+		carol: {Balance: NewAmount(2 * (100*TxGas + 1))},
+	}
+
+	type step struct {
+		tx      Transaction
+		wantErr error
+	}
+	tests := []struct {
+		name    string
+		config  TxPoolConfig
+		steps   []step
+		pending map[string][]uint64
+		queued  map[string][]uint64
+	}{
+		{
+			name:   "gap queues until filled",
+			config: DefaultTxPoolConfig,
+			steps: []step{
+				{poolTransfer(alice, 4, 100, 1), nil},
+				{poolTransfer(alice, 5, 100, 1), nil},
+				{poolTransfer(alice, 2, 100, 1), nil},
+			},
+			pending: map[string][]uint64{alice: {2}},
+			queued:  map[string][]uint64{alice: {4, 5}},
+		},
+		{
+			name:   "filled gap promotes the queue",
+			config: DefaultTxPoolConfig,
+			steps: []step{
+				{poolTransfer(alice, 4, 100, 1), nil},
+				{poolTransfer(alice, 2, 100, 1), nil},
+				{poolTransfer(alice, 3, 100, 1), nil},
+			},
+			pending: map[string][]uint64{alice: {2, 3, 4}},
+		},
+		{
+			name:   "stale and known transactions",
+			config: DefaultTxPoolConfig,
+			steps: []step{
+				{poolTransfer(alice, 1, 100, 1), ErrNonceTooLow},
+				{poolTransfer(alice, 2, 100, 1), nil},
+				{poolTransfer(alice, 2, 100, 1), ErrAlreadyKnown},
+			},
+			pending: map[string][]uint64{alice: {2}},
+		},
+		{
+			name:   "replacement needs both caps bumped",
+			config: DefaultTxPoolConfig,
+			steps: []step{
+				{poolTransfer(bob, 0, 100, 10), nil},
+				{poolTransfer(bob, 1, 100, 10), nil},
+				{poolTransfer(bob, 0, 109, 11), ErrReplaceUnderpriced},
+				{poolTransfer(bob, 0, 110, 10), ErrReplaceUnderpriced},
+				{poolTransfer(bob, 0, 110, 11), nil},
+			},
+			pending: map[string][]uint64{bob: {0, 1}},
+		},
+		{
+			name:   "sender pays for every earlier transaction",
+			config: DefaultTxPoolConfig,
+			steps: []step{
+				{poolTransfer(carol, 0, 100, 1), nil},
+				{poolTransfer(carol, 1, 100, 1), nil},
+				{poolTransfer(carol, 2, 100, 1), ErrInsufficientFunds},
+				{poolTransfer(carol, 1, 1000, 10), ErrInsufficientFunds},
+			},
+			pending: map[string][]uint64{carol: {0, 1}},
+		},
+		{
+			name:   "account slots",
+			config: TxPoolConfig{GlobalSlots: 10, AccountSlots: 2, PriceBump: 10, Lifetime: DefaultTxPoolConfig.Lifetime},
+			steps: []step{
+				{poolTransfer(bob, 0, 100, 1), nil},
+				{poolTransfer(bob, 5, 100, 1), nil},
+				{poolTransfer(bob, 1, 100, 1), ErrAccountLimitReached},
+				{poolTransfer(bob, 5, 110, 2), nil},
+			},
+			pending: map[string][]uint64{bob: {0}},
+			queued:  map[string][]uint64{bob: {5}},
+		},
+		{
+			name:   "full pool evicts the cheapest tail",
+			config: TxPoolConfig{GlobalSlots: 3, AccountSlots: 10, PriceBump: 10, Lifetime: DefaultTxPoolConfig.Lifetime},
+			steps: []step{
+				{poolTransfer(bob, 0, 100, 1), nil},
+				{poolTransfer(bob, 1, 50, 1), nil},
+				{poolTransfer(alice, 2, 80, 1), nil},
+				{poolTransfer(carol, 0, 40, 1), ErrTxPoolFull},
+				{poolTransfer(carol, 0, 60, 1), nil},
+			},
+			pending: map[string][]uint64{alice: {2}, bob: {0}, carol: {0}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pool := NewTxPool(test.config)
+			for i, step := range test.steps {
+				if err := pool.add(step.tx, state); !errors.Is(err, step.wantErr) {
+					t.Fatalf("step %d: error %v, want %v", i, err, step.wantErr)
+				}
+			}
+			if got := poolNonces(pool.pending); !reflect.DeepEqual(got, nonEmpty(test.pending)) {
+				t.Errorf("pending %v, want %v", got, test.pending)
+			}
+			if got := poolNonces(pool.queued); !reflect.DeepEqual(got, nonEmpty(test.queued)) {
+				t.Errorf("queued %v, want %v", got, test.queued)
+			}
+			if pending, queued := pool.stats(); pending+queued != len(pool.all) {
+				t.Errorf("%d pending and %d queued of %d transactions", pending, queued, len(pool.all))
+			}
+		})
+	}
+}
+
+func nonEmpty(nonces map[string][]uint64) map[string][]uint64 {
+	if nonces == nil {
+		return map[string][]uint64{}
+	}
+	return nonces
+}
+
+func TestTxPoolReset(t *testing.T) {
+	alice := "0x00000000000000000000000000000000000A11cE"
+	state := map[string]AccountState{alice: {Balance: NUSA(1)}}
+	pool := NewTxPool(DefaultTxPoolConfig)
+	for _, nonce := range []uint64{0, 1, 3, 4} {
+		if err := pool.add(poolTransfer(alice, nonce, 100, 1), state); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A block included nonces 0 to 2 from elsewhere
+	state[alice] = AccountState{Balance: NUSA(1), Nonce: 3}
+	pool.reset(state)
+	if got := poolNonces(pool.pending); !reflect.DeepEqual(got, map[string][]uint64{alice: {3, 4}}) {
+		t.Errorf("pending %v, want 3 and 4", got)
+	}
+	if len(pool.queued) != 0 || len(pool.all) != 2 {
+		t.Errorf("%d queued senders, %d transactions", len(pool.queued), len(pool.all))
+	}
+
+	// Pending transactions come out in nonce order
+	var nonces []uint64
+	for _, tx := range pool.pendingTxs() {
+		nonces = append(nonces, tx.Nonce)
+	}
+	if !reflect.DeepEqual(nonces, []uint64{3, 4}) {
+		t.Errorf("pending order %v", nonces)
+	}
+}
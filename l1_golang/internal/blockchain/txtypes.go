@@ -0,0 +1,246 @@
+package blockchain
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+// Transaction types. The type byte decides how a transaction's To, Value
+// and Data are read, how it is validated and how ChainManager executes it.
+const (
+	TxTypeTransfer          uint8 = 0  // Value from sender to To; Data is a free-form memo
+	TxTypeContractDeploy    uint8 = 1  // Data is contract code, To is empty
+	TxTypeContractCall      uint8 = 2  // Data is call input for the contract at To
+	TxTypeStake             uint8 = 3  // lock Value of the sender's balance as stake
+	TxTypeUnstake           uint8 = 4  // unbond Value of the sender's stake; see Unbonding
+	TxTypeRegisterValidator uint8 = 5  // mark the staked sender as a validator
+	TxTypeGovernanceVote    uint8 = 6  // Data is an RLP GovernanceVote
+	TxTypeDelegate          uint8 = 7  // bond Value of the sender's balance to validator To
+	TxTypeUndelegate        uint8 = 8  // unbond Value of the sender's delegation to To
+	TxTypeClaimRewards      uint8 = 9  // pay the rewards accrued on the delegation to To
+	TxTypeSetCommission     uint8 = 10 // Data is the validator's new commission, see Delegation
+	TxTypeNVSUpdate         uint8 = 11 // Data is an RLP list of NVSUpdate, sent by the NVS oracle
+)
+
+// Log topics of the non-transfer types
+const (
+	StakeTopic             = "Stake"
+	UnstakeTopic           = "Unstake"
+	ValidatorRegisterTopic = "ValidatorRegistered"
+	VoteTopic              = "Vote"
+	DelegateTopic          = "Delegate"
+	UndelegateTopic        = "Undelegate"
+	ClaimRewardsTopic      = "ClaimRewards"
+	CommissionTopic        = "Commission"
+	NVSTopic               = "NVS"
+)
+
+// Options of a governance vote
+const (
+	VoteNo      uint8 = 0
+	VoteYes     uint8 = 1
+	VoteAbstain uint8 = 2
+)
+
+// There is no VM yet; contract types are reserved in the envelope but
+// rejected until one exists
+var ErrContractsDisabled = errors.New("contract transactions are not enabled")
+
+// GovernanceVote is the Data of a TxTypeGovernanceVote transaction. The
+// vote is weighted by the voter's stake when the proposal is tallied.
+type GovernanceVote struct {
+	ProposalID uint64
+	Option     uint8
+}
+
+func EncodeGovernanceVote(vote GovernanceVote) []byte {
+	data, _ := rlp.EncodeToBytes(vote)
+	return data
+}
+
+func DecodeGovernanceVote(data []byte) (GovernanceVote, error) {
+	var vote GovernanceVote
+	if err := rlp.DecodeBytes(data, &vote); err != nil {
+		return vote, fmt.Errorf("invalid governance vote: %v", err)
+	}
+	return vote, nil
+}
+
+// txHandler validates and executes one transaction type. validate checks
+// the fields alone; execute runs after the fee has been charged, in the
+// block with the given header, and an error fails the transaction without
+// refunding the fee.
+type txHandler struct {
+	validate func(tx *Transaction) error
+	execute  func(cm *ChainManager, state *stateDB, header *BlockHeader, tx *Transaction) ([]Log, error)
+}
+
+var txHandlers = map[uint8]txHandler{
+	TxTypeTransfer:          {validateTransfer, (*ChainManager).executeTransfer},
+	TxTypeContractDeploy:    {validateContract, (*ChainManager).executeContract},
+	TxTypeContractCall:      {validateContract, (*ChainManager).executeContract},
+	TxTypeStake:             {validateStakeChange, (*ChainManager).executeStake},
+	TxTypeUnstake:           {validateStakeChange, (*ChainManager).executeUnstake},
+	TxTypeRegisterValidator: {validateRegisterValidator, (*ChainManager).executeRegisterValidator},
+	TxTypeGovernanceVote:    {validateGovernanceVote, (*ChainManager).executeGovernanceVote},
+	TxTypeDelegate:          {validateDelegationChange, (*ChainManager).executeDelegate},
+	TxTypeUndelegate:        {validateDelegationChange, (*ChainManager).executeUndelegate},
+	TxTypeClaimRewards:      {validateClaimRewards, (*ChainManager).executeClaimRewards},
+	TxTypeSetCommission:     {validateSetCommission, (*ChainManager).executeSetCommission},
+	TxTypeNVSUpdate:         {validateNVSUpdate, (*ChainManager).executeNVSUpdate},
+}
+
+// validateType runs the stateless checks of the transaction's type
+func (tx *Transaction) validateType() error {
+	handler, exists := txHandlers[tx.Type]
+	if !exists {
+		return fmt.Errorf("unknown transaction type %d", tx.Type)
+	}
+	return handler.validate(tx)
+}
+
+// executeTx applies the type-specific effect of tx and returns its logs
+func (cm *ChainManager) executeTx(state *stateDB, header *BlockHeader, tx *Transaction) ([]Log, error) {
+	handler, exists := txHandlers[tx.Type]
+	if !exists {
+		return nil, fmt.Errorf("unknown transaction type %d", tx.Type)
+	}
+	return handler.execute(cm, state, header, tx)
+}
+
+// Value the transaction takes from the sender's balance; an unstake or
+// undelegation pays out of stake instead
+func (tx *Transaction) balanceValue() Amount {
+	if tx.Type == TxTypeUnstake || tx.Type == TxTypeUndelegate {
+		return Amount{}
+	}
+	return tx.Value
+}
+
+func validateTransfer(tx *Transaction) error {
+	if tx.To == "" {
+		return fmt.Errorf("transfer without recipient")
+	}
+	if tx.Value.IsZero() {
+		return fmt.Errorf("transfer of zero value")
+	}
+	return nil
+}
+
+func (cm *ChainManager) executeTransfer(state *stateDB, header *BlockHeader, tx *Transaction) ([]Log, error) {
+	if err := cm.transfer(state, *tx); err != nil {
+		return nil, err
+	}
+	return []Log{transferLog(*tx)}, nil
+}
+
+func validateContract(tx *Transaction) error {
+	return ErrContractsDisabled
+}
+
+func (cm *ChainManager) executeContract(state *stateDB, header *BlockHeader, tx *Transaction) ([]Log, error) {
+	return nil, ErrContractsDisabled
+}
+
+func validateStakeChange(tx *Transaction) error {
+	if tx.To != "" || len(tx.Data) != 0 {
+		return fmt.Errorf("stake transactions take only a value")
+	}
+	if tx.Value.IsZero() {
+		return fmt.Errorf("stake change of zero value")
+	}
+	return nil
+}
+
+func (cm *ChainManager) executeStake(state *stateDB, header *BlockHeader, tx *Transaction) ([]Log, error) {
+	account, _ := state.getAccount(tx.From)
+	balance, err := account.Balance.Sub(tx.Value)
+	if err != nil {
+		return nil, fmt.Errorf("insufficient balance to stake %s", tx.Value)
+	}
+	stake, err := account.Stake.Add(tx.Value)
+	if err != nil {
+		return nil, err
+	}
+	account.Balance, account.Stake = balance, stake
+	state.setAccount(tx.From, account)
+	return []Log{valueLog(StakeTopic, tx.From, tx.Value)}, nil
+}
+
+// The stake stops counting at once but reaches the balance only after
+// the unbonding period. The log data is the value followed by the 8-byte
+// release height.
+func (cm *ChainManager) executeUnstake(state *stateDB, header *BlockHeader, tx *Transaction) ([]Log, error) {
+	account, _ := state.getAccount(tx.From)
+	release := header.Height + cm.config.UnbondingPeriod
+	if err := unbond(&account, tx.Value, release); err != nil {
+		return nil, err
+	}
+	state.setAccount(tx.From, account)
+
+	log := valueLog(UnstakeTopic, tx.From, tx.Value)
+	log.Data = binary.BigEndian.AppendUint64(log.Data, release)
+	return []Log{log}, nil
+}
+
+func validateRegisterValidator(tx *Transaction) error {
+	if tx.To != "" || !tx.Value.IsZero() || len(tx.Data) != 0 {
+		return fmt.Errorf("validator registration takes no recipient, value or data")
+	}
+	return nil
+}
+
+func (cm *ChainManager) executeRegisterValidator(state *stateDB, header *BlockHeader, tx *Transaction) ([]Log, error) {
+	account, _ := state.getAccount(tx.From)
+	if account.Validator {
+		return nil, fmt.Errorf("%s is already a validator", tx.From)
+	}
+	if account.Stake.IsZero() {
+		return nil, fmt.Errorf("validators need stake")
+	}
+	account.Validator = true
+	state.setAccount(tx.From, account)
+	return []Log{{Address: tx.From, Topics: []string{ValidatorRegisterTopic, tx.From}}}, nil
+}
+
+func validateGovernanceVote(tx *Transaction) error {
+	if tx.To != "" || !tx.Value.IsZero() {
+		return fmt.Errorf("governance votes take no recipient or value")
+	}
+	vote, err := DecodeGovernanceVote(tx.Data)
+	if err != nil {
+		return err
+	}
+	if vote.Option > VoteAbstain {
+		return fmt.Errorf("invalid vote option %d", vote.Option)
+	}
+	return nil
+}
+
+// Votes are recorded as logs; proposals are tallied from receipts
+func (cm *ChainManager) executeGovernanceVote(state *stateDB, header *BlockHeader, tx *Transaction) ([]Log, error) {
+	account, _ := state.getAccount(tx.From)
+	if account.Stake.IsZero() {
+		return nil, fmt.Errorf("only stakers can vote")
+	}
+	vote, _ := DecodeGovernanceVote(tx.Data)
+	weight := account.Stake.int().Bytes32()
+	return []Log{{
+		Address: tx.From,
+		Topics:  []string{VoteTopic, tx.From, fmt.Sprint(vote.ProposalID)},
+		Data:    append([]byte{vote.Option}, weight[:]...),
+	}}, nil
+}
+
+// Log with a single address topic and a 32-byte big-endian value
+func valueLog(topic, address string, value Amount) Log {
+	data := value.int().Bytes32()
+	return Log{
+		Address: address,
+		Topics:  []string{topic, address},
+		Data:    data[:],
+	}
+}
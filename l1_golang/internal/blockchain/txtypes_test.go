@@ -0,0 +1,106 @@
+package blockchain
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestValidateType(t *testing.T) {
+	to := "0x0000000000000000000000000000000000000001"
+	tests := []struct {
+		name    string
+		tx      Transaction
+		wantErr bool
+	}{
+		{"transfer", Transaction{Type: TxTypeTransfer, To: to, Value: NUSA(1)}, false},
+		{"transfer with memo", Transaction{Type: TxTypeTransfer, To: to, Value: NUSA(1), Data: []byte("memo")}, false},
+		{"transfer without recipient", Transaction{Type: TxTypeTransfer, Value: NUSA(1)}, true},
+		{"contract deploy", Transaction{Type: TxTypeContractDeploy, Data: []byte{0x60}}, true},
+		{"contract call", Transaction{Type: TxTypeContractCall, To: to, Data: []byte{0x60}}, true},
+		{"type 12", Transaction{Type: TxTypeNVSUpdate + 1, To: to, Value: NUSA(1)}, true},
+		{"type 255", Transaction{Type: 255, To: to, Value: NUSA(1)}, true},
+		{"stake", Transaction{Type: TxTypeStake, Value: NUSA(1)}, false},
+		{"stake with recipient", Transaction{Type: TxTypeStake, To: to, Value: NUSA(1)}, true},
+		{"stake with data", Transaction{Type: TxTypeStake, Value: NUSA(1), Data: []byte{1}}, true},
+		{"stake of nothing", Transaction{Type: TxTypeStake}, true},
+		{"unstake with recipient", Transaction{Type: TxTypeUnstake, To: to, Value: NUSA(1)}, true},
+		{"register", Transaction{Type: TxTypeRegisterValidator}, false},
+		{"register with recipient", Transaction{Type: TxTypeRegisterValidator, To: to}, true},
+		{"register with data", Transaction{Type: TxTypeRegisterValidator, Data: []byte{1}}, true},
+		{"register with value", Transaction{Type: TxTypeRegisterValidator, Value: NUSA(1)}, true},
+	}
+	for _, test := range tests {
+		err := test.tx.validateType()
+		if (err != nil) != test.wantErr {
+			t.Errorf("%s: error %v, want error %v", test.name, err, test.wantErr)
+		}
+		if test.tx.Type == TxTypeContractDeploy || test.tx.Type == TxTypeContractCall {
+			if !errors.Is(err, ErrContractsDisabled) {
+				t.Errorf("%s: error %v, want %v", test.name, err, ErrContractsDisabled)
+			}
+		}
+	}
+
+	// The pool turns them away before they cost anything
+	c := newTestChain(t, 1)
+	for _, typ := range []uint8{TxTypeContractDeploy, TxTypeContractCall, 12} {
+		if err := c.cm.AddTransaction(c.typed(0, 0, typ, to, NUSA(1))); err == nil {
+			t.Errorf("type %d accepted into the pool", typ)
+		}
+	}
+	if err := c.cm.AddTransaction(c.typed(0, 0, TxTypeStake, to, NUSA(1))); err == nil {
+		t.Error("stake with a recipient accepted into the pool")
+	}
+}
+
+// rlpTxPayloadUntyped is the transaction payload before the type field was
+// appended
+type rlpTxPayloadUntyped struct {
+	Nonce     uint64
+	From      string
+	To        string
+	Value     Amount
+	GasPrice  Amount
+	GasLimit  uint64
+	Data      []byte
+	Timestamp uint64
+
+	MaxFeePerGas         Amount `rlp:"optional"`
+	MaxPriorityFeePerGas Amount `rlp:"optional"`
+}
+
+func TestTransferEncodingUnchanged(t *testing.T) {
+	// Transactions from before chain IDs, so the type is the last field
+	txs := []Transaction{
+		{Nonce: 3, From: "0x0000000000000000000000000000000000000002", To: "0x0000000000000000000000000000000000000001",
+			Value: NUSA(1), GasPrice: NewAmount(7), GasLimit: TxGas, Timestamp: 1700000000},
+		{Nonce: 4, From: "0x0000000000000000000000000000000000000002", To: "0x0000000000000000000000000000000000000001",
+			Value: NUSA(1), GasLimit: TxGas, MaxFeePerGas: NewAmount(9), MaxPriorityFeePerGas: NewAmount(2), Data: []byte("memo")},
+	}
+	for _, tx := range txs {
+		typed, err := encodeVersioned(txPayload(&tx))
+		if err != nil {
+			t.Fatal(err)
+		}
+		untyped, err := encodeVersioned(rlpTxPayloadUntyped{
+			Nonce:     tx.Nonce,
+			From:      tx.From,
+			To:        tx.To,
+			Value:     tx.Value,
+			GasPrice:  tx.GasPrice,
+			GasLimit:  tx.GasLimit,
+			Data:      tx.Data,
+			Timestamp: uint64(tx.Timestamp),
+
+			MaxFeePerGas:         tx.MaxFeePerGas,
+			MaxPriorityFeePerGas: tx.MaxPriorityFeePerGas,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(typed, untyped) {
+			t.Errorf("nonce %d: type 0 encodes to %x, before types %x", tx.Nonce, typed, untyped)
+		}
+	}
+}
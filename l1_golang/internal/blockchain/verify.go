@@ -0,0 +1,127 @@
+package blockchain
+
+import (
+	"fmt"
+	"io"
+
+	"nusa-chain/internal/storage"
+)
+
+// VerifyError names the first block that failed verification
+type VerifyError struct {
+	Height uint64
+	Hash   string
+	Reason string
+}
+
+func (e *VerifyError) Error() string {
+	return fmt.Sprintf("block #%d %s: %s", e.Height, e.Hash, e.Reason)
+}
+
+// ChainVerifier re-executes a chain from genesis on a scratch in-memory
+// ChainManager, trusting nothing but the genesis config. Blocks go through
+// applyBlock, so they are held to exactly the rules AddBlock applies to a
+// new head.
+type ChainVerifier struct {
+	cm *ChainManager
+}
+
+func NewChainVerifier(config ChainConfig) (*ChainVerifier, error) {
+	cm, err := NewChainManager(config, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &ChainVerifier{cm: cm}, nil
+}
+
+// Height of the last verified block
+func (v *ChainVerifier) Height() uint64 {
+	return v.cm.latestBlock().Header.Height
+}
+
+// Supply after the last verified block
+func (v *ChainVerifier) Supply() Supply {
+	return *v.cm.supply
+}
+
+// Verify checks the next block of the chain; blocks must come in height
+// order starting with genesis. Failures are *VerifyError.
+func (v *ChainVerifier) Verify(block *Block) error {
+	hash := block.Hash()
+	fail := func(format string, args ...interface{}) error {
+		return &VerifyError{Height: block.Header.Height, Hash: hash, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	genesis := v.cm.Chain[0]
+	if block.Header.Height == 0 {
+		if hash != genesis.Hash() {
+			return fail("genesis does not match the configured genesis %s", genesis.Hash())
+		}
+		return nil
+	}
+
+	parent := v.cm.latestBlock()
+	if block.Header.Height != parent.Header.Height+1 {
+		return fail("expected block #%d next", parent.Header.Height+1)
+	}
+	if err := v.cm.applyBlock(block); err != nil {
+		return fail("%v", err)
+	}
+	return nil
+}
+
+// VerifyDatabase re-executes the canonical chain stored in db
+func VerifyDatabase(db storage.Database, config ChainConfig) (*ChainVerifier, error) {
+	verifier, err := NewChainVerifier(config)
+	if err != nil {
+		return nil, err
+	}
+	headHash, err := readHeadHash(db)
+	if err != nil {
+		return nil, err
+	}
+	if headHash == "" {
+		return nil, fmt.Errorf("database holds no chain")
+	}
+
+	for height := uint64(0); ; height++ {
+		hash, err := readCanonicalHash(db, height)
+		if err == storage.ErrNotFound {
+			break
+		}
+		if err != nil {
+			return verifier, err
+		}
+		block, err := readBlock(db, hash)
+		if err != nil {
+			return verifier, &VerifyError{Height: height, Hash: hash, Reason: fmt.Sprintf("unreadable: %v", err)}
+		}
+		if block.Hash() != hash {
+			return verifier, &VerifyError{Height: height, Hash: hash, Reason: fmt.Sprintf("stored block hashes to %s", block.Hash())}
+		}
+		if err := verifier.Verify(block); err != nil {
+			return verifier, err
+		}
+		if hash == headHash {
+			return verifier, nil
+		}
+	}
+	return verifier, fmt.Errorf("canonical chain ends at #%d before head %s", verifier.Height(), headHash)
+}
+
+// VerifyExport re-executes a chain export; it must start at genesis
+func VerifyExport(r io.Reader, config ChainConfig) (*ChainVerifier, error) {
+	verifier, err := NewChainVerifier(config)
+	if err != nil {
+		return nil, err
+	}
+	first := true
+	err = readExport(r, func(block *Block) error {
+		if first && block.Header.Height != 0 {
+			return fmt.Errorf("export starts at #%d, verification needs it to start at genesis", block.Header.Height)
+		}
+		first = false
+		return verifier.Verify(block)
+	})
+	return verifier, err
+}
@@ -0,0 +1,118 @@
+package blockchain
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+
+	"nusa-chain/internal/storage"
+)
+
+// writeExport writes blocks in the export format, whether or not they form
+// a chain
+func writeExport(t *testing.T, blocks ...*Block) *bytes.Buffer {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(exportMagic)
+	for _, block := range blocks {
+		data, err := EncodeBlock(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var size [binary.MaxVarintLen64]byte
+		zw.Write(size[:binary.PutUvarint(size[:], uint64(len(data)))])
+		zw.Write(data)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return &buf
+}
+
+func TestVerifyDatabase(t *testing.T) {
+	tampered := []struct {
+		name   string
+		edit   func(block *Block)
+		reason string
+	}{
+		{"untouched", func(block *Block) {}, ""},
+		{"state root", func(block *Block) { block.Header.StateRoot = testHashes(1)[0] }, "state root"},
+		{"reward", func(block *Block) { block.Header.Reward = NUSA(3) }, "exceeds the block reward"},
+	}
+	for _, test := range tampered {
+		db := storage.NewMemoryDB()
+		c := newTestChain(t, 1).fork(db)
+		recipient := keyAddress(newTestKey(t))
+		c.mine(c.transfer(0, 0, recipient, NUSA(1)))
+
+		// Block #2 is edited, re-signed and written straight to the
+		// datadir, as a corrupt or malicious one would hold it
+		block := c.build(c.transfer(0, 1, recipient, NUSA(1)))
+		test.edit(block)
+		if err := block.Sign(c.producer); err != nil {
+			t.Fatal(err)
+		}
+		batch := db.NewBatch()
+		if err := writeBlock(batch, block, 0); err != nil {
+			t.Fatal(err)
+		}
+		writeCanonicalHead(batch, block)
+		if err := batch.Write(); err != nil {
+			t.Fatal(err)
+		}
+
+		verifier, err := VerifyDatabase(db, c.config)
+		if test.reason == "" {
+			if err != nil || verifier.Height() != 2 {
+				t.Fatalf("%s: verified to #%d: %v", test.name, verifier.Height(), err)
+			}
+			continue
+		}
+		var verifyErr *VerifyError
+		if !errors.As(err, &verifyErr) || verifyErr.Height != 2 || !strings.Contains(verifyErr.Reason, test.reason) {
+			t.Errorf("%s: error %v, want %q at #2", test.name, err, test.reason)
+		}
+		if verifier.Height() != 1 {
+			t.Errorf("%s: verified to #%d", test.name, verifier.Height())
+		}
+	}
+}
+
+func TestVerifyExport(t *testing.T) {
+	a := newTestChain(t, 1)
+	b := a.fork(nil)
+	recipient := keyAddress(newTestKey(t))
+	a.mine(a.transfer(0, 0, recipient, NUSA(1)))
+	a.mine()
+	b.mine()
+	b.mine()
+
+	var export bytes.Buffer
+	if _, err := a.cm.ExportBlocks(&export, 0, 2); err != nil {
+		t.Fatal(err)
+	}
+	verifier, err := VerifyExport(&export, a.config)
+	if err != nil || verifier.Height() != 2 {
+		t.Fatalf("verified to #%d: %v", verifier.Height(), err)
+	}
+	if verifier.Supply() != a.cm.GetSupply() {
+		t.Errorf("supply %+v, chain has %+v", verifier.Supply(), a.cm.GetSupply())
+	}
+
+	// b's second block does not follow a's first
+	genesis, _ := a.cm.GetBlockByHeight(0)
+	a1, _ := a.cm.GetBlockByHeight(1)
+	b2, _ := b.cm.GetBlockByHeight(2)
+	verifier, err = VerifyExport(writeExport(t, genesis, a1, b2), a.config)
+	var verifyErr *VerifyError
+	if !errors.As(err, &verifyErr) || verifyErr.Height != 2 || verifyErr.Hash != b2.Hash() {
+		t.Errorf("broken parent link: error %v, want a failure at #2", err)
+	}
+
+	if _, err := VerifyExport(writeExport(t, a1), a.config); err == nil {
+		t.Error("verified an export that skips genesis")
+	}
+}
@@ -0,0 +1,310 @@
+package consensus
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ethereum/go-ethereum/common"
+
+	"nusa-chain/internal/blockchain"
+)
+
+// Ticks of the block production loop before an unfinished vote round is
+// abandoned and the head is voted on again in the next round
+const finalityTimeout = 3
+
+// voteRound tallies the prevotes and precommits of one round on the head.
+// A validator prevotes the head when the round starts, precommits once
+// prevotes backed by more than 2/3 of the stake agree on a block, and the
+// precommits reaching the same quorum justify that block.
+type voteRound struct {
+	head         *blockchain.Block
+	height       uint64
+	round        uint64
+	hash         string
+	validators   []blockchain.ValidatorInfo
+	prevotes     *blockchain.VoteSet
+	precommits   *blockchain.VoteSet
+	prevoted     bool
+	precommitted bool
+	ticks        int
+
+	// Votes on the head from later rounds, the latest round of each
+	// validator only
+	ahead map[common.Address][]blockchain.Vote
+}
+
+// SetVoteBroadcaster sets how this node's votes reach the other
+// validators; broadcast receives blockchain.EncodeVote output
+func (p *PoVCReal) SetVoteBroadcaster(broadcast func([]byte)) {
+	p.voteMutex.Lock()
+	defer p.voteMutex.Unlock()
+	p.broadcastVote = broadcast
+}
+
+// HandleVote counts a vote received from another validator
+func (p *PoVCReal) HandleVote(vote blockchain.Vote) error {
+	p.voteMutex.Lock()
+	defer p.voteMutex.Unlock()
+	return p.addVote(vote)
+}
+
+// voteOnHead starts a round on the head if it is not final yet, or the
+// next round if the current one ran out of time
+func (p *PoVCReal) voteOnHead() {
+	p.voteMutex.Lock()
+	defer p.voteMutex.Unlock()
+
+	head := p.chainManager.GetLatestBlock()
+	if head.Header.Height <= p.chainManager.FinalizedHeight() {
+		p.votes = nil
+		return
+	}
+
+	round := uint64(0)
+	if p.votes != nil && p.votes.hash == head.Hash() {
+		if p.votes.ticks++; p.votes.ticks < finalityTimeout {
+			return
+		}
+		round = p.votes.round + 1
+	}
+	p.startRound(head, round)
+}
+
+// startRound tallies votes on head from scratch and prevotes it; the
+// caller holds voteMutex
+func (p *PoVCReal) startRound(head *blockchain.Block, round uint64) {
+	validators, err := p.chainManager.VoteValidators(head.Hash())
+	if err != nil {
+		fmt.Printf("⚠️  Cannot vote on block #%d: %v\n", head.Header.Height, err)
+		return
+	}
+	votes := &voteRound{
+		head:       head,
+		height:     head.Header.Height,
+		round:      round,
+		hash:       head.Hash(),
+		validators: validators,
+		prevotes:   blockchain.NewVoteSet(blockchain.VotePrevote, head.Header.Height, round, validators),
+		precommits: blockchain.NewVoteSet(blockchain.VotePrecommit, head.Header.Height, round, validators),
+		ahead:      make(map[common.Address][]blockchain.Vote),
+	}
+
+	// Votes from rounds still ahead stay; those for this round are
+	// counted once the round is set up
+	var joined []blockchain.Vote
+	if p.votes != nil && p.votes.hash == votes.hash {
+		for validator, kept := range p.votes.ahead {
+			if kept[0].Round > round {
+				votes.ahead[validator] = kept
+			} else if kept[0].Round == round {
+				joined = append(joined, kept...)
+			}
+		}
+	}
+	p.votes = votes
+
+	if p.mayVoteFor(head) {
+		p.castVote(blockchain.VotePrevote)
+	}
+	for _, vote := range joined {
+		if p.votes != votes {
+			return
+		}
+		if err := p.addVote(vote); err != nil && err != blockchain.ErrDuplicateVote {
+			fmt.Printf("⚠️  Dropped vote of %s: %v\n", vote.Validator, err)
+		}
+	}
+}
+
+// Once this node precommits a block it only votes for that block and its
+// descendants, so it never helps justify a conflicting branch
+func (p *PoVCReal) mayVoteFor(block *blockchain.Block) bool {
+	if p.locked == nil || p.locked.Header.Height <= p.chainManager.FinalizedHeight() {
+		return true
+	}
+	if block.Header.Height < p.locked.Header.Height {
+		return false
+	}
+	ancestor, err := p.chainManager.GetBlockByHeight(p.locked.Header.Height)
+	return err == nil && ancestor.Hash() == p.locked.Hash()
+}
+
+// Whether this node locked before the given height and round
+func (p *PoVCReal) lockedBefore(height, round uint64) bool {
+	if p.locked == nil {
+		return false
+	}
+	return p.locked.Header.Height < height || (p.locked.Header.Height == height && p.lockedRound < round)
+}
+
+// castVote signs this node's vote for the round's block, if its wallet is
+// a validator, counts it and broadcasts it
+func (p *PoVCReal) castVote(voteType uint8) {
+	if voteType == blockchain.VotePrevote {
+		p.votes.prevoted = true
+	}
+	if !p.votes.prevotes.IsValidator(p.wallet.Address.Hex()) {
+		return
+	}
+
+	vote := blockchain.Vote{
+		Type:      voteType,
+		Height:    p.votes.height,
+		Round:     p.votes.round,
+		BlockHash: p.votes.hash,
+		Validator: p.wallet.Address.Hex(),
+	}
+	if err := p.wallet.SignVote(&vote); err != nil {
+		fmt.Printf("⚠️  Failed to sign vote: %v\n", err)
+		return
+	}
+	if p.broadcastVote != nil {
+		if data, err := blockchain.EncodeVote(&vote); err == nil {
+			p.broadcastVote(data)
+		}
+	}
+	if err := p.addVote(vote); err != nil {
+		fmt.Printf("⚠️  Failed to count own vote: %v\n", err)
+	}
+}
+
+// addVote counts a vote and moves the round on when a quorum forms; the
+// caller holds voteMutex
+func (p *PoVCReal) addVote(vote blockchain.Vote) error {
+	// Votes arriving after their block was finalized are of no use
+	if vote.Height <= p.chainManager.FinalizedHeight() {
+		return nil
+	}
+
+	// A validator already voting on the head gets this node voting too
+	head := p.chainManager.GetLatestBlock()
+	if vote.Height == head.Header.Height && (p.votes == nil || p.votes.hash != head.Hash()) {
+		p.startRound(head, 0)
+	}
+	if p.votes != nil && vote.Height == p.votes.height && vote.Round > p.votes.round {
+		return p.addAheadVote(vote)
+	}
+	if p.votes == nil || vote.Height != p.votes.height || vote.Round != p.votes.round {
+		return fmt.Errorf("vote for %d/%d is not for the current round", vote.Height, vote.Round)
+	}
+
+	votes := p.votes.prevotes
+	if vote.Type == blockchain.VotePrecommit {
+		votes = p.votes.precommits
+	}
+	if err := votes.Add(vote); err != nil {
+		return err
+	}
+	return p.tally()
+}
+
+// tally acts on the quorums of the current round; the caller holds
+// voteMutex
+func (p *PoVCReal) tally() error {
+	votes := p.votes
+
+	// Precommit once the stake agrees on the round's block
+	if hash, ok := votes.prevotes.Quorum(); ok && hash == votes.hash && !votes.precommitted {
+		// A lock only holds against rounds that came before it. More than
+		// 2/3 prevoting a conflicting block later means the locked block
+		// can no longer be justified, so this node follows the stake.
+		if !p.mayVoteFor(votes.head) && p.lockedBefore(votes.height, votes.round) {
+			fmt.Printf("🔓 Unlocked block #%d for block #%d in round %d\n", p.locked.Header.Height, votes.height, votes.round)
+			p.locked = nil
+		}
+		if p.mayVoteFor(votes.head) {
+			votes.precommitted = true
+			p.locked, p.lockedRound = votes.head, votes.round
+			if !votes.prevoted {
+				p.castVote(blockchain.VotePrevote)
+			}
+			if p.votes == votes {
+				p.castVote(blockchain.VotePrecommit)
+			}
+		}
+		// Our votes may have completed the round
+		if p.votes != votes {
+			return nil
+		}
+	}
+
+	hash, ok := votes.precommits.Quorum()
+	if !ok {
+		return nil
+	}
+	justification := &blockchain.Justification{
+		Height:     votes.height,
+		Round:      votes.round,
+		BlockHash:  hash,
+		Precommits: votes.precommits.Votes(hash),
+	}
+	p.votes = nil
+	if err := p.chainManager.AddJustification(justification); err != nil {
+		return fmt.Errorf("failed to finalize block #%d: %v", justification.Height, err)
+	}
+	fmt.Printf("🔒 Finalized block #%d with %d precommits\n", justification.Height, len(justification.Precommits))
+	return nil
+}
+
+// addAheadVote keeps a vote from a later round than this node's. Once
+// validators holding more than 1/3 of the stake have voted in later
+// rounds at least one honest validator got there, and this node follows;
+// a single key cannot drag it into rounds of its choosing.
+func (p *PoVCReal) addAheadVote(vote blockchain.Vote) error {
+	check := blockchain.NewVoteSet(vote.Type, vote.Height, vote.Round, p.votes.validators)
+	if err := check.Add(vote); err != nil {
+		return err
+	}
+
+	validator := common.HexToAddress(vote.Validator)
+	kept := p.votes.ahead[validator]
+	switch {
+	case len(kept) == 0 || kept[0].Round < vote.Round:
+		p.votes.ahead[validator] = []blockchain.Vote{vote}
+	case kept[0].Round == vote.Round:
+		for _, other := range kept {
+			if other.Type == vote.Type {
+				return blockchain.ErrDuplicateVote
+			}
+		}
+		p.votes.ahead[validator] = append(kept, vote)
+	default:
+		return nil
+	}
+
+	if round, ok := p.votes.roundToJoin(); ok {
+		p.startRound(p.votes.head, round)
+	}
+	return nil
+}
+
+// roundToJoin returns the latest round that validators holding more than
+// 1/3 of the stake have reached, a validator counting for every round up
+// to the one it voted in
+func (r *voteRound) roundToJoin() (uint64, bool) {
+	stakes := make(map[common.Address]blockchain.Amount)
+	total := blockchain.Amount{}
+	for _, validator := range r.validators {
+		stakes[common.HexToAddress(validator.Address)] = validator.Stake
+		total, _ = total.Add(validator.Stake)
+	}
+
+	validators := make([]common.Address, 0, len(r.ahead))
+	for validator := range r.ahead {
+		validators = append(validators, validator)
+	}
+	sort.Slice(validators, func(i, j int) bool {
+		return r.ahead[validators[i]][0].Round > r.ahead[validators[j]][0].Round
+	})
+
+	threshold := total.MulDiv(1, 3)
+	reached := blockchain.Amount{}
+	for _, validator := range validators {
+		reached, _ = reached.Add(stakes[validator])
+		if reached.Cmp(threshold) > 0 {
+			return r.ahead[validator][0].Round, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,235 @@
+package consensus
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"nusa-chain/internal/blockchain"
+	"nusa-chain/internal/wallet"
+)
+
+// testNet is a set of validators with equal stake whose votes are queued
+// until delivered
+type testNet struct {
+	config blockchain.ChainConfig
+	nodes  []*PoVCReal
+	queue  []blockchain.Vote
+}
+
+func newTestNet(t *testing.T, size int) *testNet {
+	net := &testNet{}
+	var wallets []*wallet.Wallet
+	for i := 0; i < size; i++ {
+		w, err := wallet.NewWallet()
+		if err != nil {
+			t.Fatal(err)
+		}
+		wallets = append(wallets, w)
+		net.config.GenesisAccounts = append(net.config.GenesisAccounts, blockchain.GenesisAccount{
+			Address: w.Address.Hex(), Stake: blockchain.NUSA(10), Validator: true,
+		})
+	}
+	net.config.ChainID = 1
+	net.config.BlockReward = blockchain.NUSA(2)
+
+	for _, w := range wallets {
+		cm, err := blockchain.NewChainManager(net.config, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		node := NewPoVCReal(cm, w, "")
+		node.SetVoteBroadcaster(func(data []byte) {
+			vote, err := blockchain.DecodeVote(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			net.queue = append(net.queue, *vote)
+		})
+		net.nodes = append(net.nodes, node)
+	}
+	return net
+}
+
+// build seals and signs a block on top of cm's head, dated ts seconds
+// after it
+func (net *testNet) build(t *testing.T, cm *blockchain.ChainManager, ts int64) *blockchain.Block {
+	head := cm.GetLatestBlock()
+	timestamp := time.Now().Unix() - 100 + ts
+	proposer := cm.ProposerAt(timestamp)
+	block := blockchain.NewBlock(head.Header.Height+1, head.Hash(), nil, proposer)
+	block.Header.Timestamp = timestamp
+	if err := cm.SealStateRoot(block); err != nil {
+		t.Fatal(err)
+	}
+	for _, node := range net.nodes {
+		if strings.EqualFold(node.wallet.Address.Hex(), proposer) {
+			if err := node.wallet.SignBlock(block); err != nil {
+				t.Fatal(err)
+			}
+			return block
+		}
+	}
+	t.Fatalf("no node for proposer %s", proposer)
+	return nil
+}
+
+// addBlocks hands blocks to every node
+func (net *testNet) addBlocks(t *testing.T, blocks ...*blockchain.Block) {
+	for _, block := range blocks {
+		for _, node := range net.nodes {
+			if err := node.chainManager.AddBlock(block); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+// deliver hands the queued votes of the given type to the nodes in to,
+// dropping all others
+func (net *testNet) deliver(t *testing.T, voteType uint8, to ...*PoVCReal) {
+	for len(net.queue) > 0 {
+		vote := net.queue[0]
+		net.queue = net.queue[1:]
+		if vote.Type != voteType {
+			continue
+		}
+		for _, node := range to {
+			if err := node.HandleVote(vote); err != nil && err != blockchain.ErrDuplicateVote {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func vote(t *testing.T, node *PoVCReal, voteType uint8, block *blockchain.Block, round uint64) blockchain.Vote {
+	v := blockchain.Vote{
+		Type:      voteType,
+		Height:    block.Header.Height,
+		Round:     round,
+		BlockHash: block.Hash(),
+		Validator: node.wallet.Address.Hex(),
+	}
+	if err := node.wallet.SignVote(&v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestVotesFinalizeHead(t *testing.T) {
+	net := newTestNet(t, 4)
+	block := net.build(t, net.nodes[0].chainManager, 1)
+	net.addBlocks(t, block)
+
+	for _, node := range net.nodes[:3] {
+		node.voteOnHead()
+	}
+	for len(net.queue) > 0 {
+		vote := net.queue[0]
+		net.queue = net.queue[1:]
+		for _, node := range net.nodes {
+			if err := node.HandleVote(vote); err != nil && err != blockchain.ErrDuplicateVote {
+				t.Fatal(err)
+			}
+		}
+	}
+	for i, node := range net.nodes {
+		if finalized := node.chainManager.FinalizedBlock(); finalized.Hash() != block.Hash() {
+			t.Fatalf("node %d finalized #%d, want #1", i, finalized.Header.Height)
+		}
+	}
+}
+
+func TestRoundJumpNeedsOneThirdStake(t *testing.T) {
+	net := newTestNet(t, 4)
+	block := net.build(t, net.nodes[0].chainManager, 1)
+	net.addBlocks(t, block)
+	node := net.nodes[0]
+	node.voteOnHead()
+
+	outsider, err := wallet.NewWallet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stranger := &PoVCReal{wallet: outsider}
+
+	steps := []struct {
+		name    string
+		vote    blockchain.Vote
+		wantErr bool
+		round   uint64
+	}{
+		{"non-validator", vote(t, stranger, blockchain.VotePrevote, block, 7), true, 0},
+		{"one validator of four", vote(t, net.nodes[1], blockchain.VotePrevote, block, 5), false, 0},
+		{"same validator again", vote(t, net.nodes[1], blockchain.VotePrecommit, block, 5), false, 0},
+		{"second validator", vote(t, net.nodes[2], blockchain.VotePrevote, block, 3), false, 3},
+	}
+	for _, step := range steps {
+		err := node.HandleVote(step.vote)
+		if (err != nil) != step.wantErr {
+			t.Fatalf("%s: error %v, want error %v", step.name, err, step.wantErr)
+		}
+		if node.votes.round != step.round {
+			t.Fatalf("%s: in round %d, want %d", step.name, node.votes.round, step.round)
+		}
+	}
+
+	// The round joined counts the votes that led there
+	if _, ok := node.votes.prevotes.Quorum(); ok {
+		t.Fatal("quorum from two prevotes")
+	}
+	if err := node.HandleVote(vote(t, net.nodes[3], blockchain.VotePrevote, block, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if hash, ok := node.votes.prevotes.Quorum(); !ok || hash != block.Hash() {
+		t.Fatal("no prevote quorum in round 3")
+	}
+}
+
+func TestPolkaOnLaterBlockUnlocks(t *testing.T) {
+	net := newTestNet(t, 4)
+	node := net.nodes[0]
+
+	// Node 0 precommits and locks on a, but the precommits never arrive
+	a := net.build(t, node.chainManager, 1)
+	net.addBlocks(t, a)
+	for _, n := range net.nodes[:3] {
+		n.voteOnHead()
+	}
+	net.deliver(t, blockchain.VotePrevote, node)
+	if node.locked != a {
+		t.Fatal("node did not lock on a")
+	}
+
+	// A longer branch without a takes over
+	other, err := blockchain.NewChainManager(net.config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b1 := net.build(t, other, 2)
+	if err := other.AddBlock(b1); err != nil {
+		t.Fatal(err)
+	}
+	b2 := net.build(t, other, 3)
+	net.addBlocks(t, b1, b2)
+	if node.chainManager.GetLatestBlock() != b2 {
+		t.Fatal("no reorg to b2")
+	}
+
+	// The lock keeps node 0 from prevoting b2 until the others do
+	node.voteOnHead()
+	if len(node.votes.prevotes.Votes(b2.Hash())) != 0 {
+		t.Fatal("prevoted b2 while locked on a")
+	}
+	net.queue = nil
+	for _, n := range net.nodes[1:] {
+		n.voteOnHead()
+	}
+	net.deliver(t, blockchain.VotePrevote, node)
+	if node.locked != b2 {
+		t.Fatal("polka on b2 did not move the lock")
+	}
+	if len(node.votes.precommits.Votes(b2.Hash())) != 1 {
+		t.Fatal("node 0 did not precommit b2")
+	}
+}
@@ -1,38 +1,52 @@
 package consensus
 
 import (
+	"bytes"
 	"time"
-	"math/rand"
+	"math"
+	"sort"
 	"sync"
 	"fmt"
 	"encoding/json"
 	"net/http"
+	"strings"
 	
 	"nusa-chain/internal/blockchain"
+	"nusa-chain/internal/wallet"
 )
 
 type PoVCReal struct {
 	chainManager *blockchain.ChainManager
+	wallet       *wallet.Wallet // signs the blocks this node produces
 	aiEngineURL  string
-	validators   map[string]Validator
 	mutex        sync.RWMutex
 	isProducing  bool
 	stopChan     chan bool
+	
+	// Finality votes, see finality.go
+	voteMutex     sync.Mutex
+	votes         *voteRound
+	locked        *blockchain.Block // last block this node precommitted
+	lockedRound   uint64
+	broadcastVote func([]byte)
 }
 
+// Blocks between two NVS score updates from the oracle
+const nvsPublishInterval = 100
+
 type Validator struct {
 	Address     string `json:"address"`
-	Stake       uint64 `json:"stake"`
+	Stake       blockchain.Amount `json:"stake"`
 	NVSScore    float64 `json:"nvs_score"`
 	LastActive  int64  `json:"last_active"`
 	IsActive    bool   `json:"is_active"`
 }
 
-func NewPoVCReal(chainManager *blockchain.ChainManager, aiEngineURL string) *PoVCReal {
+func NewPoVCReal(chainManager *blockchain.ChainManager, w *wallet.Wallet, aiEngineURL string) *PoVCReal {
 	return &PoVCReal{
 		chainManager: chainManager,
+		wallet:       w,
 		aiEngineURL:  aiEngineURL,
-		validators:   make(map[string]Validator),
 		stopChan:     make(chan bool),
 	}
 }
@@ -59,6 +73,7 @@ func (p *PoVCReal) blockProductionLoop() {
 			if p.shouldProduceBlock() {
 				p.produceBlock()
 			}
+			p.voteOnHead()
 		case <-p.stopChan:
 			return
 		}
@@ -72,22 +87,9 @@ func (p *PoVCReal) shouldProduceBlock() bool {
 		return true // Genesis block
 	}
 	
-	// Simple round-robin validator selection
-	validators := p.getActiveValidators()
-	if len(validators) == 0 {
-		return true // No validators, produce anyway
-	}
-	
-	// Get our validator index
-	myAddress := "validator_address" // TODO: Get from wallet
-	for i, validator := range validators {
-		if validator.Address == myAddress {
-			// Check if it's our turn based on block height
-			return (latestBlock.Header.Height+1)%uint64(len(validators)) == uint64(i)
-		}
-	}
-	
-	return false
+	// Produce only in our turn; anyone may until a validator registers
+	proposer := p.chainManager.NextProposer()
+	return proposer == "" || strings.EqualFold(proposer, p.wallet.Address.Hex())
 }
 
 func (p *PoVCReal) produceBlock() {
@@ -103,26 +105,49 @@ func (p *PoVCReal) produceBlock() {
 		prevHash = latestBlock.Hash()
 	}
 	
-	// Get pending transactions
-	pendingTXs := p.chainManager.GetPendingTXs()
-	
-	// Limit transactions per block
-	maxTXs := 100
-	if len(pendingTXs) > maxTXs {
-		pendingTXs = pendingTXs[:maxTXs]
-	}
-	
 	// Create new block
 	newBlock := blockchain.NewBlock(
 		height,
 		prevHash,
-		pendingTXs,
-		"validator_address", // TODO: Get from wallet
+		nil,
+		p.wallet.Address.Hex(),
 	)
 	
+	// Rewards go to the validator exactly as the schedule spells it for
+	// the block's round
+	if proposer := p.chainManager.ProposerAt(newBlock.Header.Timestamp); proposer != "" {
+		newBlock.Header.Validator = proposer
+	}
+	
+	// Fill the block up to its gas limit with transactions that cover the base fee
+	baseFee := p.chainManager.NextBaseFee()
+	var pendingTXs []blockchain.Transaction
+	var gas uint64
+	for _, tx := range p.chainManager.GetPendingTXs() {
+		if gas+tx.GasLimit > newBlock.Header.GasLimit || tx.FeeCap().Cmp(baseFee) < 0 {
+			continue
+		}
+		gas += tx.GasLimit
+		pendingTXs = append(pendingTXs, tx)
+	}
+	newBlock.Transactions = pendingTXs
+	newBlock.Header.MerkleRoot = newBlock.CalculateMerkleRoot()
+	
 	// Apply PoVC adjustments based on AI Engine
 	p.applyPoVCRewards(newBlock)
 	
+	// Commit to the post-execution state
+	if err := p.chainManager.SealStateRoot(newBlock); err != nil {
+		fmt.Printf("❌ Failed to execute block: %v\n", err)
+		return
+	}
+	
+	// Sign the sealed header
+	if err := p.wallet.SignBlock(newBlock); err != nil {
+		fmt.Printf("❌ Failed to sign block: %v\n", err)
+		return
+	}
+	
 	// Add block to chain
 	if err := p.chainManager.AddBlock(newBlock); err != nil {
 		fmt.Printf("❌ Failed to add block: %v\n", err)
@@ -141,9 +166,12 @@ func (p *PoVCReal) applyPoVCRewards(block *blockchain.Block) {
 		validatorScore := nvsScores[block.Header.Validator]
 		if validatorScore > 0 {
 			// Reward multiplier based on NVS score
-			multiplier := validatorScore // 0-1 scale
-			adjustedReward := uint64(float64(block.Header.Reward) * multiplier)
-			block.Header.Reward = adjustedReward
+			// 0-1 scale, applied in millionths so the reward stays integral
+			multiplier := uint64(validatorScore * 1000000)
+			adjustedReward, err := block.Header.Reward.MulUint64(multiplier)
+			if err == nil {
+				block.Header.Reward = adjustedReward.DivUint64(1000000)
+			}
 		}
 	}
 	
@@ -183,6 +211,8 @@ func (p *PoVCReal) getNVSScoresFromAI(block *blockchain.Block) map[string]float6
 		return make(map[string]float64)
 	}
 	
+	p.publishScores(result.Data.Scores, block.Header.Height)
+	
 	return result.Data.Scores
 }
 
@@ -190,115 +220,89 @@ func (p *PoVCReal) applyAntiWhalePenalties(block *blockchain.Block) {
 	// Check if validator is a whale
 	for _, tx := range block.Transactions {
 		senderBalance := p.chainManager.GetBalance(tx.From)
-		totalSupply := uint64(25000000 * 1000000000000000000) // 25M NUSA in wei
+		totalSupply := blockchain.NUSA(25000000) // 25M NUSA in wei
 		
-		percentage := float64(senderBalance) / float64(totalSupply) * 100
+		percentage := senderBalance.Float64() / totalSupply.Float64() * 100
 		
 		// Apply penalties for whales
-		if percentage > 2 {
+		if exceedsShare(senderBalance, totalSupply, 2, 100) {
 			// Whale detected - reduce reward
-			block.Header.Reward = 0
+			block.Header.Reward = blockchain.Amount{}
 			fmt.Printf("⚠️  Whale detected: %s (%f%% of supply)\n", tx.From, percentage)
-		} else if percentage > 0.5 {
+		} else if exceedsShare(senderBalance, totalSupply, 5, 1000) {
 			// Warning zone - partial penalty
-			penalty := uint64(float64(block.Header.Reward) * 0.5)
-			block.Header.Reward -= penalty
+			block.Header.Reward = block.Header.Reward.DivUint64(2)
 		}
 	}
 }
 
+// balance > supply * num/den, compared without floating point
+func exceedsShare(balance, supply blockchain.Amount, num, den uint64) bool {
+	scaled, err := balance.MulUint64(den)
+	if err != nil {
+		return true
+	}
+	limit, err := supply.MulUint64(num)
+	if err != nil {
+		return false
+	}
+	return scaled.Cmp(limit) > 0
+}
+
+// The validator set is whoever registered on chain and still has stake,
+// weighted by the NVS scores the oracle posted
 func (p *PoVCReal) getActiveValidators() []Validator {
-	p.mutex.RLock()
-	defer p.mutex.RUnlock()
-	
 	var active []Validator
-	for _, validator := range p.validators {
-		if validator.IsActive && validator.Stake > 0 {
-			active = append(active, validator)
-		}
+	for _, validator := range p.chainManager.GetValidators() {
+		active = append(active, Validator{
+			Address:    validator.Address,
+			Stake:      validator.Stake,
+			NVSScore:   float64(validator.NVSScore) / blockchain.MaxNVSScore,
+			LastActive: validator.LastActive,
+			IsActive:   true,
+		})
 	}
 	return active
 }
 
-// Register validator
-func (p *PoVCReal) RegisterValidator(address string, stake uint64) error {
-	p.mutex.Lock()
-	defer p.mutex.Unlock()
-	
-	// Get NVS score from AI Engine
-	nvsScore, err := p.getValidatorScore(address)
-	if err != nil {
-		return err
+// publishScores posts the AI Engine's scores on chain every
+// nvsPublishInterval blocks, if this node's wallet is the NVS oracle
+func (p *PoVCReal) publishScores(scores map[string]float64, height uint64) {
+	from := p.wallet.Address.Hex()
+	if len(scores) == 0 || height%nvsPublishInterval != 0 || !strings.EqualFold(p.chainManager.NVSOracle(), from) {
+		return
 	}
 	
-	p.validators[address] = Validator{
-		Address:    address,
-		Stake:      stake,
-		NVSScore:   nvsScore,
-		LastActive: time.Now().Unix(),
-		IsActive:   true,
+	// Scores are 0-1; on chain they are basis points from 1 up
+	addresses := make([]string, 0, len(scores))
+	for address := range scores {
+		addresses = append(addresses, address)
 	}
-	
-	return nil
-}
-
-func (p *PoVCReal) getValidatorScore(address string) (float64, error) {
-	client := &http.Client{Timeout: 5 * time.Second}
-	
-	requestData := map[string]interface{}{
-		"wallet_address": address,
-		"wallet_balance": p.chainManager.GetBalance(address),
+	sort.Strings(addresses)
+	var updates []blockchain.NVSUpdate
+	for _, address := range addresses {
+		score := math.Round(scores[address] * blockchain.MaxNVSScore)
+		score = math.Max(1, math.Min(score, blockchain.MaxNVSScore))
+		updates = append(updates, blockchain.NVSUpdate{Address: address, Score: uint16(score)})
 	}
 	
-	jsonData, _ := json.Marshal(requestData)
-	
-	resp, err := client.Post(p.aiEngineURL+"/povc/calculate", "application/json", bytes.NewBuffer(jsonData))
-	if err != nil {
-		return 0.5, err // Default score
+	tx, err := p.chainManager.CreateTransaction(from, "", blockchain.Amount{}, blockchain.EncodeNVSUpdates(updates))
+	if err == nil {
+		tx.Type = blockchain.TxTypeNVSUpdate
+		err = p.wallet.SignTransaction(tx)
 	}
-	defer resp.Body.Close()
-	
-	var result struct {
-		Success bool `json:"success"`
-		Data    struct {
-			NVSScore float64 `json:"nvs_score"`
-		} `json:"data"`
+	if err == nil {
+		err = p.chainManager.AddTransaction(*tx)
 	}
-	
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return 0.5, err
+	if err != nil {
+		fmt.Printf("⚠️  Failed to publish NVS scores: %v\n", err)
+		return
 	}
-	
-	return result.Data.NVSScore, nil
+	fmt.Printf("📊 Published NVS scores of %d validators\n", len(updates))
 }
 
-// Select validator for next block
+// Select validator for next block: the proposer every node derives from
+// the head, see blockchain.ChainManager.NextProposer
 func (p *PoVCReal) SelectValidator() string {
-	validators := p.getActiveValidators()
-	if len(validators) == 0 {
-		return ""
-	}
-	
-	// Weighted selection based on NVS score
-	var totalWeight float64
-	for _, v := range validators {
-		totalWeight += v.NVSScore
-	}
-	
-	if totalWeight == 0 {
-		// Random selection if no scores
-		return validators[rand.Intn(len(validators))].Address
-	}
-	
-	// Weighted random selection
-	r := rand.Float64() * totalWeight
-	var cumulative float64
-	for _, v := range validators {
-		cumulative += v.NVSScore
-		if r <= cumulative {
-			return v.Address
-		}
-	}
-	
-	return validators[len(validators)-1].Address
-}
\ No newline at end of file
+	return p.chainManager.NextProposer()
+}
@@ -191,6 +191,7 @@ func (n *P2PNetwork) handleStream(s network.Stream) {
 	// This would parse and process different message types
 }
 
+// BroadcastBlock sends blockchain.EncodeBlock output to every connected peer
 func (n *P2PNetwork) BroadcastBlock(blockData []byte) {
 	n.peerMutex.RLock()
 	defer n.peerMutex.RUnlock()
@@ -202,6 +203,7 @@ func (n *P2PNetwork) BroadcastBlock(blockData []byte) {
 	}
 }
 
+// BroadcastTransaction sends blockchain.EncodeTransaction output to every connected peer
 func (n *P2PNetwork) BroadcastTransaction(txData []byte) {
 	n.peerMutex.RLock()
 	defer n.peerMutex.RUnlock()
@@ -213,6 +215,18 @@ func (n *P2PNetwork) BroadcastTransaction(txData []byte) {
 	}
 }
 
+// BroadcastVote sends blockchain.EncodeVote output to every connected peer
+func (n *P2PNetwork) BroadcastVote(voteData []byte) {
+	n.peerMutex.RLock()
+	defer n.peerMutex.RUnlock()
+	
+	for _, peer := range n.peers {
+		if peer.Connected {
+			go n.sendToPeer(peer.ID, "/nusa/vote", voteData)
+		}
+	}
+}
+
 func (n *P2PNetwork) sendToPeer(peerID peer.ID, protocol string, data []byte) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
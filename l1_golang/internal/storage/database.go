@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrNotFound = errors.New("storage: key not found")
+
+// Database is the key-value store the chain persists into
+type Database interface {
+	Get(key []byte) ([]byte, error)
+	Has(key []byte) (bool, error)
+	Put(key []byte, value []byte) error
+	Delete(key []byte) error
+	NewBatch() Batch
+	NewIterator(prefix []byte, start []byte) Iterator
+	Close() error
+}
+
+// Batch collects writes and commits them atomically
+type Batch interface {
+	Put(key []byte, value []byte)
+	Delete(key []byte)
+	Len() int
+	Write() error
+	Reset()
+}
+
+// Iterator walks keys sharing a prefix in ascending order, beginning at
+// prefix+start (start may be nil)
+type Iterator interface {
+	Next() bool
+	Key() []byte
+	Value() []byte
+	Release()
+	Error() error
+}
+
+// Open database by type name (matches node config "database.type")
+func Open(dbType string, path string) (Database, error) {
+	switch dbType {
+	case "leveldb", "":
+		return NewLevelDB(path)
+	case "memory":
+		return NewMemoryDB(), nil
+	default:
+		return nil, fmt.Errorf("unsupported database type: %s", dbType)
+	}
+}
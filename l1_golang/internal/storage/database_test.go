@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestDatabases(t *testing.T) {
+	backends := []struct {
+		name string
+		open func(t *testing.T) Database
+	}{
+		{"memory", func(t *testing.T) Database { return NewMemoryDB() }},
+		{"leveldb", func(t *testing.T) Database {
+			db, err := NewLevelDB(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return db
+		}},
+	}
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			db := backend.open(t)
+			defer db.Close()
+
+			if err := db.Put([]byte("a1"), []byte("one")); err != nil {
+				t.Fatal(err)
+			}
+			if value, err := db.Get([]byte("a1")); err != nil || string(value) != "one" {
+				t.Fatalf("a1 = %q, %v", value, err)
+			}
+			if _, err := db.Get([]byte("missing")); err != ErrNotFound {
+				t.Fatalf("missing key: %v, want ErrNotFound", err)
+			}
+
+			// A batch is invisible until written, then lands whole
+			batch := db.NewBatch()
+			for _, key := range []string{"a3", "a2", "b1"} {
+				batch.Put([]byte(key), []byte("v"+key))
+			}
+			batch.Delete([]byte("a1"))
+			if has, _ := db.Has([]byte("a2")); has || batch.Len() != 4 {
+				t.Fatalf("batch of %d applied before Write", batch.Len())
+			}
+			if err := batch.Write(); err != nil {
+				t.Fatal(err)
+			}
+			if has, _ := db.Has([]byte("a1")); has {
+				t.Fatal("a1 survived the batch delete")
+			}
+
+			tests := []struct {
+				prefix, start string
+				want          []string
+			}{
+				{"a", "", []string{"a2=va2", "a3=va3"}},
+				{"a", "3", []string{"a3=va3"}},
+				{"b", "", []string{"b1=vb1"}},
+				{"c", "", nil},
+			}
+			for _, test := range tests {
+				it := db.NewIterator([]byte(test.prefix), []byte(test.start))
+				var got []string
+				for it.Next() {
+					got = append(got, fmt.Sprintf("%s=%s", it.Key(), it.Value()))
+				}
+				if err := it.Error(); err != nil {
+					t.Fatal(err)
+				}
+				it.Release()
+				if !reflect.DeepEqual(got, test.want) {
+					t.Errorf("prefix %q from %q: %v, want %v", test.prefix, test.start, got, test.want)
+				}
+			}
+		})
+	}
+}
+
+func TestLevelDBReopen(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open("leveldb", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := db.NewBatch()
+	batch.Put([]byte("head"), []byte("block"))
+	if err := batch.Write(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err = Open("leveldb", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if value, err := db.Get([]byte("head")); err != nil || string(value) != "block" {
+		t.Fatalf("head = %q after reopening, %v", value, err)
+	}
+
+	if _, err := Open("sqlite", dir); err == nil {
+		t.Error("opened an unsupported database type")
+	}
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"os"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	lerrors "github.com/syndtr/goleveldb/leveldb/errors"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+type LevelDB struct {
+	db *leveldb.DB
+}
+
+// Open (or create) a LevelDB datadir
+func NewLevelDB(path string) (*LevelDB, error) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, err
+	}
+
+	db, err := leveldb.OpenFile(path, &opt.Options{
+		OpenFilesCacheCapacity: 64,
+		BlockCacheCapacity:     16 * opt.MiB,
+		WriteBuffer:            8 * opt.MiB,
+	})
+	if _, corrupted := err.(*lerrors.ErrCorrupted); corrupted {
+		// Unclean shutdown can leave the manifest behind the journal
+		db, err = leveldb.RecoverFile(path, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &LevelDB{db: db}, nil
+}
+
+func (l *LevelDB) Get(key []byte) ([]byte, error) {
+	value, err := l.db.Get(key, nil)
+	if err == leveldb.ErrNotFound {
+		return nil, ErrNotFound
+	}
+	return value, err
+}
+
+func (l *LevelDB) Has(key []byte) (bool, error) {
+	return l.db.Has(key, nil)
+}
+
+func (l *LevelDB) Put(key []byte, value []byte) error {
+	return l.db.Put(key, value, &opt.WriteOptions{Sync: true})
+}
+
+func (l *LevelDB) Delete(key []byte) error {
+	return l.db.Delete(key, &opt.WriteOptions{Sync: true})
+}
+
+func (l *LevelDB) NewBatch() Batch {
+	return &levelBatch{db: l.db, batch: new(leveldb.Batch)}
+}
+
+func (l *LevelDB) NewIterator(prefix []byte, start []byte) Iterator {
+	keyRange := util.BytesPrefix(prefix)
+	keyRange.Start = append(append([]byte{}, prefix...), start...)
+	return l.db.NewIterator(keyRange, nil)
+}
+
+func (l *LevelDB) Close() error {
+	return l.db.Close()
+}
+
+type levelBatch struct {
+	db    *leveldb.DB
+	batch *leveldb.Batch
+}
+
+func (b *levelBatch) Put(key []byte, value []byte) {
+	b.batch.Put(key, value)
+}
+
+func (b *levelBatch) Delete(key []byte) {
+	b.batch.Delete(key)
+}
+
+func (b *levelBatch) Len() int {
+	return b.batch.Len()
+}
+
+// Write commits the batch with fsync, so either all of it survives a crash or none of it does
+func (b *levelBatch) Write() error {
+	return b.db.Write(b.batch, &opt.WriteOptions{Sync: true})
+}
+
+func (b *levelBatch) Reset() {
+	b.batch.Reset()
+}
@@ -0,0 +1,147 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MemoryDB is a non-persistent Database for dev nodes and tooling
+type MemoryDB struct {
+	data  map[string][]byte
+	mutex sync.RWMutex
+}
+
+func NewMemoryDB() *MemoryDB {
+	return &MemoryDB{data: make(map[string][]byte)}
+}
+
+func (m *MemoryDB) Get(key []byte) ([]byte, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	value, exists := m.data[string(key)]
+	if !exists {
+		return nil, ErrNotFound
+	}
+	return append([]byte{}, value...), nil
+}
+
+func (m *MemoryDB) Has(key []byte) (bool, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	_, exists := m.data[string(key)]
+	return exists, nil
+}
+
+func (m *MemoryDB) Put(key []byte, value []byte) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.data[string(key)] = append([]byte{}, value...)
+	return nil
+}
+
+func (m *MemoryDB) Delete(key []byte) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.data, string(key))
+	return nil
+}
+
+func (m *MemoryDB) NewBatch() Batch {
+	return &memoryBatch{db: m}
+}
+
+func (m *MemoryDB) NewIterator(prefix []byte, start []byte) Iterator {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	first := string(prefix) + string(start)
+	var keys []string
+	for key := range m.data {
+		if strings.HasPrefix(key, string(prefix)) && key >= first {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		values[i] = m.data[key]
+	}
+
+	return &memoryIterator{keys: keys, values: values, index: -1}
+}
+
+func (m *MemoryDB) Close() error {
+	return nil
+}
+
+type memoryOp struct {
+	key    string
+	value  []byte
+	delete bool
+}
+
+type memoryBatch struct {
+	db  *MemoryDB
+	ops []memoryOp
+}
+
+func (b *memoryBatch) Put(key []byte, value []byte) {
+	b.ops = append(b.ops, memoryOp{key: string(key), value: append([]byte{}, value...)})
+}
+
+func (b *memoryBatch) Delete(key []byte) {
+	b.ops = append(b.ops, memoryOp{key: string(key), delete: true})
+}
+
+func (b *memoryBatch) Len() int {
+	return len(b.ops)
+}
+
+func (b *memoryBatch) Write() error {
+	b.db.mutex.Lock()
+	defer b.db.mutex.Unlock()
+
+	for _, op := range b.ops {
+		if op.delete {
+			delete(b.db.data, op.key)
+		} else {
+			b.db.data[op.key] = op.value
+		}
+	}
+	return nil
+}
+
+func (b *memoryBatch) Reset() {
+	b.ops = nil
+}
+
+type memoryIterator struct {
+	keys   []string
+	values [][]byte
+	index  int
+}
+
+func (it *memoryIterator) Next() bool {
+	it.index++
+	return it.index < len(it.keys)
+}
+
+func (it *memoryIterator) Key() []byte {
+	return []byte(it.keys[it.index])
+}
+
+func (it *memoryIterator) Value() []byte {
+	return it.values[it.index]
+}
+
+func (it *memoryIterator) Release() {}
+
+func (it *memoryIterator) Error() error {
+	return nil
+}
@@ -0,0 +1,78 @@
+package trie
+
+// Keys are handled as nibble sequences ("hex" form). A terminator nibble (16)
+// marks keys that end at a value. On disk keys use the compact hex-prefix
+// encoding from the Ethereum yellow paper.
+
+const terminator = 16
+
+func keybytesToHex(str []byte) []byte {
+	l := len(str)*2 + 1
+	nibbles := make([]byte, l)
+	for i, b := range str {
+		nibbles[i*2] = b / 16
+		nibbles[i*2+1] = b % 16
+	}
+	nibbles[l-1] = terminator
+	return nibbles
+}
+
+func hexToCompact(hex []byte) []byte {
+	flag := byte(0)
+	if hasTerm(hex) {
+		flag = 1
+		hex = hex[:len(hex)-1]
+	}
+
+	buf := make([]byte, len(hex)/2+1)
+	buf[0] = flag << 5 // flag byte
+	if len(hex)&1 == 1 {
+		buf[0] |= 1 << 4 // odd flag
+		buf[0] |= hex[0] // first nibble is contained in the first byte
+		hex = hex[1:]
+	}
+	for bi, ni := 0, 0; ni < len(hex); bi, ni = bi+1, ni+2 {
+		buf[bi+1] = hex[ni]<<4 | hex[ni+1]
+	}
+	return buf
+}
+
+func compactToHex(compact []byte) []byte {
+	if len(compact) == 0 {
+		return compact
+	}
+
+	// Extension keys carry no terminator
+	base := keybytesToHex(compact)
+	if base[0] < 2 {
+		base = base[:len(base)-1]
+	}
+
+	// Apply odd flag
+	chop := 2 - base[0]&1
+	return base[chop:]
+}
+
+func hasTerm(s []byte) bool {
+	return len(s) > 0 && s[len(s)-1] == terminator
+}
+
+func prefixLen(a, b []byte) int {
+	i, length := 0, len(a)
+	if len(b) < length {
+		length = len(b)
+	}
+	for ; i < length; i++ {
+		if a[i] != b[i] {
+			break
+		}
+	}
+	return i
+}
+
+func concat(s1 []byte, s2 ...byte) []byte {
+	r := make([]byte, len(s1)+len(s2))
+	copy(r, s1)
+	copy(r[len(s1):], s2)
+	return r
+}
@@ -0,0 +1,158 @@
+package trie
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/rlp"
+
+	"nusa-chain/internal/storage"
+)
+
+type node interface{}
+
+type (
+	// Leaf (Val is a valueNode) or extension (Val is a child node)
+	shortNode struct {
+		Key   []byte
+		Val   node
+		flags nodeFlag
+	}
+	// Branch with 16 children plus an optional value in slot 16
+	fullNode struct {
+		Children [17]node
+		flags    nodeFlag
+	}
+	hashNode  []byte
+	valueNode []byte
+)
+
+// nodeFlag caches a node's hash and tracks whether it still has to be written
+type nodeFlag struct {
+	hash  hashNode
+	dirty bool
+}
+
+func newFlag() nodeFlag {
+	return nodeFlag{dirty: true}
+}
+
+func (n *fullNode) copy() *fullNode {
+	c := *n
+	return &c
+}
+
+var nodePrefix = []byte("t")
+
+func nodeKey(hash []byte) []byte {
+	return append(append([]byte{}, nodePrefix...), hash...)
+}
+
+// Every node is referenced by hash (no inlining), so a node encodes as an
+// RLP list of byte strings: [compactKey, value|childHash] for short nodes
+// and 16 child hashes plus the value for full nodes.
+func encodeNode(n node, batch storage.Batch) ([]byte, error) {
+	switch n := n.(type) {
+	case *shortNode:
+		var ref []byte
+		if value, ok := n.Val.(valueNode); ok {
+			ref = value
+		} else {
+			child, err := hashNodeRec(n.Val, batch)
+			if err != nil {
+				return nil, err
+			}
+			ref = child
+		}
+		return rlp.EncodeToBytes([][]byte{hexToCompact(n.Key), ref})
+
+	case *fullNode:
+		items := make([][]byte, 17)
+		for i := 0; i < 16; i++ {
+			if n.Children[i] == nil {
+				items[i] = []byte{}
+				continue
+			}
+			child, err := hashNodeRec(n.Children[i], batch)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = child
+		}
+		if value, ok := n.Children[16].(valueNode); ok {
+			items[16] = value
+		} else {
+			items[16] = []byte{}
+		}
+		return rlp.EncodeToBytes(items)
+
+	default:
+		return nil, fmt.Errorf("trie: cannot encode %T", n)
+	}
+}
+
+// hashNodeRec returns the hash of n, caching it on the node. With a non-nil
+// batch every dirty node below n is written out and marked clean.
+func hashNodeRec(n node, batch storage.Batch) (hashNode, error) {
+	var flags *nodeFlag
+	switch n := n.(type) {
+	case hashNode:
+		return n, nil
+	case *shortNode:
+		flags = &n.flags
+	case *fullNode:
+		flags = &n.flags
+	default:
+		return nil, fmt.Errorf("trie: cannot hash %T", n)
+	}
+
+	if flags.hash != nil && (!flags.dirty || batch == nil) {
+		return flags.hash, nil
+	}
+
+	enc, err := encodeNode(n, batch)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.Sum256(enc)
+	flags.hash = hash[:]
+	if batch != nil {
+		batch.Put(nodeKey(flags.hash), enc)
+		flags.dirty = false
+	}
+	return flags.hash, nil
+}
+
+func decodeNode(hash []byte, data []byte) (node, error) {
+	var items [][]byte
+	if err := rlp.DecodeBytes(data, &items); err != nil {
+		return nil, fmt.Errorf("trie: corrupt node %x: %v", hash, err)
+	}
+
+	switch len(items) {
+	case 2:
+		n := &shortNode{Key: compactToHex(items[0]), flags: nodeFlag{hash: hash}}
+		if hasTerm(n.Key) {
+			n.Val = valueNode(items[1])
+		} else {
+			n.Val = hashNode(items[1])
+		}
+		return n, nil
+
+	case 17:
+		n := &fullNode{flags: nodeFlag{hash: hash}}
+		for i := 0; i < 16; i++ {
+			if len(items[i]) > 0 {
+				n.Children[i] = hashNode(items[i])
+			}
+		}
+		if len(items[16]) > 0 {
+			n.Children[16] = valueNode(items[16])
+		}
+		return n, nil
+
+	default:
+		return nil, fmt.Errorf("trie: corrupt node %x: %d items", hash, len(items))
+	}
+}
@@ -0,0 +1,107 @@
+package trie
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// Prove returns the encoded nodes on the path to key, root first. When key
+// is not in the trie the nodes prove its absence instead.
+func (t *Trie) Prove(key []byte) ([][]byte, error) {
+	var proof [][]byte
+	path := keybytesToHex(key)
+	n := t.root
+	for n != nil {
+		if hash, ok := n.(hashNode); ok {
+			resolved, err := t.resolve(hash)
+			if err != nil {
+				return nil, err
+			}
+			n = resolved
+		}
+		enc, err := encodeNode(n, nil)
+		if err != nil {
+			return nil, err
+		}
+		proof = append(proof, enc)
+
+		switch cur := n.(type) {
+		case *shortNode:
+			if len(path) < len(cur.Key) || !bytes.Equal(cur.Key, path[:len(cur.Key)]) {
+				return proof, nil
+			}
+			if _, ok := cur.Val.(valueNode); ok {
+				return proof, nil
+			}
+			path = path[len(cur.Key):]
+			n = cur.Val
+		case *fullNode:
+			if _, ok := cur.Children[path[0]].(valueNode); ok {
+				return proof, nil
+			}
+			n = cur.Children[path[0]]
+			path = path[1:]
+		default:
+			return nil, fmt.Errorf("trie: invalid node %T", n)
+		}
+	}
+	return proof, nil
+}
+
+// VerifyProof checks a proof made by Prove against root and returns the
+// value stored under key, or nil if the proof shows key is absent. It only
+// needs the proof, not the database.
+func VerifyProof(root string, key []byte, proof [][]byte) ([]byte, error) {
+	if root == EmptyRoot && len(proof) == 0 {
+		return nil, nil
+	}
+	want, err := hex.DecodeString(root)
+	if err != nil {
+		return nil, fmt.Errorf("trie: invalid root %q: %v", root, err)
+	}
+
+	// The walk has to end at the last node of the proof
+	result := func(i int, value []byte) ([]byte, error) {
+		if i != len(proof)-1 {
+			return nil, fmt.Errorf("trie: proof has %d unused nodes", len(proof)-1-i)
+		}
+		return value, nil
+	}
+
+	path := keybytesToHex(key)
+	for i, enc := range proof {
+		hash := sha256.Sum256(enc)
+		if !bytes.Equal(hash[:], want) {
+			return nil, fmt.Errorf("trie: proof node %d does not match its reference", i)
+		}
+		n, err := decodeNode(hash[:], enc)
+		if err != nil {
+			return nil, err
+		}
+
+		var next node
+		switch n := n.(type) {
+		case *shortNode:
+			if len(path) < len(n.Key) || !bytes.Equal(n.Key, path[:len(n.Key)]) {
+				return result(i, nil)
+			}
+			path = path[len(n.Key):]
+			next = n.Val
+		case *fullNode:
+			next = n.Children[path[0]]
+			path = path[1:]
+		}
+
+		switch next := next.(type) {
+		case nil:
+			return result(i, nil)
+		case valueNode:
+			return result(i, next)
+		case hashNode:
+			want = next
+		}
+	}
+	return nil, fmt.Errorf("trie: proof is incomplete")
+}
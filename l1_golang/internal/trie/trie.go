@@ -0,0 +1,270 @@
+package trie
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/rlp"
+
+	"nusa-chain/internal/storage"
+)
+
+// Root of the empty trie: sha256 of the RLP empty string
+var EmptyRoot = func() string {
+	enc, _ := rlp.EncodeToBytes([]byte{})
+	hash := sha256.Sum256(enc)
+	return hex.EncodeToString(hash[:])
+}()
+
+// Trie is a Merkle Patricia trie whose nodes live in a storage.Database.
+// Updates never modify stored nodes, so every committed root stays readable.
+type Trie struct {
+	root node
+	db   storage.Database
+}
+
+// Open the trie with the given hex root ("" for an empty trie)
+func New(root string, db storage.Database) (*Trie, error) {
+	t := &Trie{db: db}
+	if root == "" || root == EmptyRoot {
+		return t, nil
+	}
+
+	hash, err := hex.DecodeString(root)
+	if err != nil {
+		return nil, fmt.Errorf("trie: invalid root %q: %v", root, err)
+	}
+	if _, err := t.resolve(hashNode(hash)); err != nil {
+		return nil, err
+	}
+	t.root = hashNode(hash)
+	return t, nil
+}
+
+// Copy is cheap: nodes are copy-on-write
+func (t *Trie) Copy() *Trie {
+	return &Trie{root: t.root, db: t.db}
+}
+
+func (t *Trie) Get(key []byte) ([]byte, error) {
+	return t.get(t.root, keybytesToHex(key))
+}
+
+// Update stores value under key; an empty value deletes the key
+func (t *Trie) Update(key, value []byte) error {
+	if len(value) == 0 {
+		return t.Delete(key)
+	}
+
+	root, err := t.insert(t.root, keybytesToHex(key), valueNode(append([]byte{}, value...)))
+	if err != nil {
+		return err
+	}
+	t.root = root
+	return nil
+}
+
+func (t *Trie) Delete(key []byte) error {
+	root, err := t.delete(t.root, keybytesToHex(key))
+	if err != nil {
+		return err
+	}
+	t.root = root
+	return nil
+}
+
+// Hash returns the hex root hash without writing anything
+func (t *Trie) Hash() (string, error) {
+	if t.root == nil {
+		return EmptyRoot, nil
+	}
+	hash, err := hashNodeRec(t.root, nil)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash), nil
+}
+
+// Commit stages all dirty nodes into batch and returns the hex root hash
+func (t *Trie) Commit(batch storage.Batch) (string, error) {
+	if t.root == nil {
+		return EmptyRoot, nil
+	}
+	hash, err := hashNodeRec(t.root, batch)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash), nil
+}
+
+func (t *Trie) resolve(hash hashNode) (node, error) {
+	data, err := t.db.Get(nodeKey(hash))
+	if err != nil {
+		return nil, fmt.Errorf("trie: missing node %x: %v", []byte(hash), err)
+	}
+	return decodeNode(hash, data)
+}
+
+func (t *Trie) get(n node, key []byte) ([]byte, error) {
+	switch n := n.(type) {
+	case nil:
+		return nil, nil
+	case valueNode:
+		return n, nil
+	case *shortNode:
+		if len(key) < len(n.Key) || !bytes.Equal(n.Key, key[:len(n.Key)]) {
+			return nil, nil
+		}
+		return t.get(n.Val, key[len(n.Key):])
+	case *fullNode:
+		return t.get(n.Children[key[0]], key[1:])
+	case hashNode:
+		resolved, err := t.resolve(n)
+		if err != nil {
+			return nil, err
+		}
+		return t.get(resolved, key)
+	default:
+		return nil, fmt.Errorf("trie: invalid node %T", n)
+	}
+}
+
+func (t *Trie) insert(n node, key []byte, value node) (node, error) {
+	if len(key) == 0 {
+		return value, nil
+	}
+
+	switch n := n.(type) {
+	case nil:
+		return &shortNode{Key: key, Val: value, flags: newFlag()}, nil
+
+	case *shortNode:
+		matchlen := prefixLen(key, n.Key)
+		// Whole key matches, keep this node and insert below it
+		if matchlen == len(n.Key) {
+			child, err := t.insert(n.Val, key[matchlen:], value)
+			if err != nil {
+				return nil, err
+			}
+			return &shortNode{Key: n.Key, Val: child, flags: newFlag()}, nil
+		}
+
+		// Otherwise branch out at the index where the keys differ
+		branch := &fullNode{flags: newFlag()}
+		var err error
+		branch.Children[n.Key[matchlen]], err = t.insert(nil, n.Key[matchlen+1:], n.Val)
+		if err != nil {
+			return nil, err
+		}
+		branch.Children[key[matchlen]], err = t.insert(nil, key[matchlen+1:], value)
+		if err != nil {
+			return nil, err
+		}
+		if matchlen == 0 {
+			return branch, nil
+		}
+		return &shortNode{Key: key[:matchlen], Val: branch, flags: newFlag()}, nil
+
+	case *fullNode:
+		child, err := t.insert(n.Children[key[0]], key[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		branch := n.copy()
+		branch.flags = newFlag()
+		branch.Children[key[0]] = child
+		return branch, nil
+
+	case hashNode:
+		resolved, err := t.resolve(n)
+		if err != nil {
+			return nil, err
+		}
+		return t.insert(resolved, key, value)
+
+	default:
+		return nil, fmt.Errorf("trie: invalid node %T", n)
+	}
+}
+
+func (t *Trie) delete(n node, key []byte) (node, error) {
+	switch n := n.(type) {
+	case nil:
+		return nil, nil
+
+	case valueNode:
+		return nil, nil
+
+	case *shortNode:
+		matchlen := prefixLen(key, n.Key)
+		if matchlen < len(n.Key) {
+			return n, nil // key not present
+		}
+		if matchlen == len(key) {
+			return nil, nil // remove the leaf entirely
+		}
+
+		child, err := t.delete(n.Val, key[len(n.Key):])
+		if err != nil {
+			return nil, err
+		}
+		// Merge with a short child so no extension points at another short node
+		if short, ok := child.(*shortNode); ok {
+			return &shortNode{Key: concat(n.Key, short.Key...), Val: short.Val, flags: newFlag()}, nil
+		}
+		return &shortNode{Key: n.Key, Val: child, flags: newFlag()}, nil
+
+	case *fullNode:
+		child, err := t.delete(n.Children[key[0]], key[1:])
+		if err != nil {
+			return nil, err
+		}
+		branch := n.copy()
+		branch.flags = newFlag()
+		branch.Children[key[0]] = child
+
+		// Collapse the branch if only one child is left
+		pos := -1
+		for i, c := range branch.Children {
+			if c != nil {
+				if pos != -1 {
+					return branch, nil
+				}
+				pos = i
+			}
+		}
+		if pos == -1 {
+			return nil, nil
+		}
+		if pos != terminator {
+			remaining, err := t.resolveChild(branch.Children[pos])
+			if err != nil {
+				return nil, err
+			}
+			if short, ok := remaining.(*shortNode); ok {
+				key := concat([]byte{byte(pos)}, short.Key...)
+				return &shortNode{Key: key, Val: short.Val, flags: newFlag()}, nil
+			}
+		}
+		return &shortNode{Key: []byte{byte(pos)}, Val: branch.Children[pos], flags: newFlag()}, nil
+
+	case hashNode:
+		resolved, err := t.resolve(n)
+		if err != nil {
+			return nil, err
+		}
+		return t.delete(resolved, key)
+
+	default:
+		return nil, fmt.Errorf("trie: invalid node %T", n)
+	}
+}
+
+func (t *Trie) resolveChild(n node) (node, error) {
+	if hash, ok := n.(hashNode); ok {
+		return t.resolve(hash)
+	}
+	return n, nil
+}
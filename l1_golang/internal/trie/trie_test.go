@@ -0,0 +1,176 @@
+package trie
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"nusa-chain/internal/storage"
+)
+
+type kv struct {
+	key, value string
+}
+
+// rootOf builds a fresh trie from pairs, inserted in order
+func rootOf(t *testing.T, pairs []kv) string {
+	t.Helper()
+	tr, _ := New("", storage.NewMemoryDB())
+	for _, pair := range pairs {
+		if err := tr.Update([]byte(pair.key), []byte(pair.value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root, err := tr.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return root
+}
+
+func TestTrieOperations(t *testing.T) {
+	// "do", "dog" and "doge" share extensions; "horse" branches at the root
+	base := []kv{{"do", "verb"}, {"dog", "puppy"}, {"doge", "coin"}, {"horse", "stallion"}}
+
+	type op struct {
+		key, value string // an empty value deletes
+	}
+	tests := []struct {
+		name string
+		ops  []op
+		want []kv // the trie must match a fresh one holding exactly these
+	}{
+		{"insert", nil, base},
+		{"update", []op{{"dog", "hound"}}, []kv{{"do", "verb"}, {"dog", "hound"}, {"doge", "coin"}, {"horse", "stallion"}}},
+		{"delete a leaf under an extension", []op{{"doge", ""}}, []kv{{"do", "verb"}, {"dog", "puppy"}, {"horse", "stallion"}}},
+		{"delete a value in a branch", []op{{"dog", ""}}, []kv{{"do", "verb"}, {"doge", "coin"}, {"horse", "stallion"}}},
+		{"delete collapses the root branch", []op{{"horse", ""}}, []kv{{"do", "verb"}, {"dog", "puppy"}, {"doge", "coin"}}},
+		{"delete down to one key", []op{{"do", ""}, {"dog", ""}, {"horse", ""}}, []kv{{"doge", "coin"}}},
+		{"delete a missing key", []op{{"dot", ""}, {"d", ""}, {"horses", ""}}, base},
+		{"delete everything", []op{{"do", ""}, {"dog", ""}, {"doge", ""}, {"horse", ""}}, nil},
+	}
+	for _, test := range tests {
+		tr, _ := New("", storage.NewMemoryDB())
+		for _, pair := range base {
+			if err := tr.Update([]byte(pair.key), []byte(pair.value)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for _, op := range test.ops {
+			if err := tr.Update([]byte(op.key), []byte(op.value)); err != nil {
+				t.Fatalf("%s: %v", test.name, err)
+			}
+		}
+
+		want := make(map[string]string)
+		for _, pair := range test.want {
+			want[pair.key] = pair.value
+		}
+		for _, key := range []string{"d", "do", "dog", "doge", "dot", "horse", "horses"} {
+			got, err := tr.Get([]byte(key))
+			if err != nil {
+				t.Fatalf("%s: get %q: %v", test.name, key, err)
+			}
+			if string(got) != want[key] {
+				t.Errorf("%s: %q = %q, want %q", test.name, key, got, want[key])
+			}
+		}
+
+		// A collapsed node hashes the same as one that was never split
+		root, _ := tr.Hash()
+		if fresh := rootOf(t, test.want); root != fresh {
+			t.Errorf("%s: root %s, fresh trie gives %s", test.name, root, fresh)
+		}
+	}
+	if root := rootOf(t, nil); root != EmptyRoot {
+		t.Errorf("empty trie root %s, want %s", root, EmptyRoot)
+	}
+}
+
+func TestTrieInsertionOrder(t *testing.T) {
+	var pairs []kv
+	for i := 0; i < 200; i++ {
+		pairs = append(pairs, kv{fmt.Sprintf("key%d", i), fmt.Sprintf("value%d", i)})
+	}
+	want := rootOf(t, pairs)
+
+	reversed := make([]kv, len(pairs))
+	for i, pair := range pairs {
+		reversed[len(pairs)-1-i] = pair
+	}
+	if root := rootOf(t, reversed); root != want {
+		t.Fatalf("reversed order: root %s, want %s", root, want)
+	}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 5; i++ {
+		r.Shuffle(len(pairs), func(i, j int) { pairs[i], pairs[j] = pairs[j], pairs[i] })
+		if root := rootOf(t, pairs); root != want {
+			t.Fatalf("shuffle %d: root %s, want %s", i, root, want)
+		}
+	}
+}
+
+func TestTrieReopen(t *testing.T) {
+	db := storage.NewMemoryDB()
+	tr, _ := New("", db)
+	for i := 0; i < 50; i++ {
+		tr.Update([]byte(fmt.Sprintf("key%d", i)), []byte(fmt.Sprintf("value%d", i)))
+	}
+	batch := db.NewBatch()
+	first, err := tr.Commit(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := batch.Write(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Changes after the commit leave the committed root readable
+	tr.Update([]byte("key0"), []byte("changed"))
+	tr.Delete([]byte("key1"))
+	batch = db.NewBatch()
+	second, _ := tr.Commit(batch)
+	batch.Write()
+
+	for _, root := range []string{first, second} {
+		reopened, err := New(root, db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hash, _ := reopened.Hash(); hash != root {
+			t.Errorf("reopened root %s, want %s", hash, root)
+		}
+		for i := 0; i < 50; i++ {
+			key := fmt.Sprintf("key%d", i)
+			want := fmt.Sprintf("value%d", i)
+			if root == second && i == 0 {
+				want = "changed"
+			} else if root == second && i == 1 {
+				want = ""
+			}
+			if got, err := reopened.Get([]byte(key)); err != nil || string(got) != want {
+				t.Fatalf("root %s: %q = %q (%v), want %q", root, key, got, err, want)
+			}
+		}
+	}
+
+	if _, err := New(rootOf(t, []kv{{"never", "committed"}}), db); err == nil {
+		t.Error("opened a root that was never committed")
+	}
+	if _, err := New("not hex", db); err == nil {
+		t.Error("opened a malformed root")
+	}
+}
+
+func TestTrieRootCommitsToValues(t *testing.T) {
+	// Two states whose only difference is one balance
+	balances := func(alice string) []kv {
+		return []kv{{"alice", alice}, {"bob", "100"}, {"carol", "7"}}
+	}
+	if rootOf(t, balances("100")) == rootOf(t, balances("101")) {
+		t.Error("different balances give the same root")
+	}
+	if rootOf(t, balances("100")) != rootOf(t, balances("100")) {
+		t.Error("same balances give different roots")
+	}
+}
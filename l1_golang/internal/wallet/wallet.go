@@ -2,15 +2,17 @@ package wallet
 
 import (
 	"crypto/ecdsa"
-	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"math/big"
+	"strings"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
+
+	"nusa-chain/internal/blockchain"
 )
 
 type Wallet struct {
@@ -39,21 +41,38 @@ func NewWallet() (*Wallet, error) {
 	}, nil
 }
 
+// Sign returns a recoverable [R || S || V] signature over sha256(data), hex encoded
 func (w *Wallet) Sign(data []byte) (string, error) {
 	// Hash the data
 	hash := sha256.Sum256(data)
 
 	// Sign the hash
-	r, s, err := ecdsa.Sign(rand.Reader, w.PrivateKey, hash[:])
+	signature, err := crypto.Sign(hash[:], w.PrivateKey)
 	if err != nil {
 		return "", err
 	}
 
-	// Encode signature
-	signature := append(r.Bytes(), s.Bytes()...)
 	return hex.EncodeToString(signature), nil
 }
 
+// SignTransaction fills in the hash and signature of a transaction sent from this wallet
+func (w *Wallet) SignTransaction(tx *blockchain.Transaction) error {
+	if tx.From == "" {
+		tx.From = w.Address.Hex()
+	}
+	return tx.Sign(w.PrivateKey)
+}
+
+// SignBlock signs a sealed block this wallet's address validates
+func (w *Wallet) SignBlock(block *blockchain.Block) error {
+	return block.Sign(w.PrivateKey)
+}
+
+// SignVote signs a finality vote cast by this wallet's address
+func (w *Wallet) SignVote(vote *blockchain.Vote) error {
+	return vote.Sign(w.PrivateKey)
+}
+
 func VerifySignature(publicKey *ecdsa.PublicKey, data []byte, signature string) bool {
 	// Decode signature
 	sigBytes, err := hex.DecodeString(signature)
@@ -61,18 +80,33 @@ func VerifySignature(publicKey *ecdsa.PublicKey, data []byte, signature string)
 		return false
 	}
 
-	if len(sigBytes) != 64 {
+	if len(sigBytes) != 65 {
 		return false
 	}
 
-	r := new(big.Int).SetBytes(sigBytes[:32])
-	s := new(big.Int).SetBytes(sigBytes[32:])
-
 	// Hash the data
 	hash := sha256.Sum256(data)
 
 	// Verify signature
-	return ecdsa.Verify(publicKey, hash[:], r, s)
+	return crypto.VerifySignature(crypto.FromECDSAPub(publicKey), hash[:], sigBytes[:64])
+}
+
+// RecoverAddress returns the address that produced a signature from Sign
+func RecoverAddress(data []byte, signature string) (common.Address, error) {
+	sigBytes, err := hex.DecodeString(signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+	if len(sigBytes) != 65 {
+		return common.Address{}, fmt.Errorf("invalid signature length %d", len(sigBytes))
+	}
+
+	hash := sha256.Sum256(data)
+	publicKey, err := crypto.SigToPub(hash[:], sigBytes)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*publicKey), nil
 }
 
 func (w *Wallet) GetPrivateKeyHex() string {
@@ -90,6 +124,27 @@ func (w *Wallet) String() string {
 	)
 }
 
+// ParseNUSA converts a decimal token amount such as "1.5" to wei
+func ParseNUSA(s string) (blockchain.Amount, error) {
+	whole, frac, _ := strings.Cut(strings.TrimSpace(s), ".")
+	if len(frac) > 18 {
+		return blockchain.Amount{}, fmt.Errorf("invalid amount %q: more than 18 decimals", s)
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	return blockchain.ParseAmount(whole + frac + strings.Repeat("0", 18-len(frac)))
+}
+
+// FormatNUSA renders wei as a decimal token amount without trailing zeros
+func FormatNUSA(a blockchain.Amount) string {
+	whole, frac := new(big.Int).QuoRem(a.Big(), big.NewInt(blockchain.WeiPerNUSA), new(big.Int))
+	if frac.Sign() == 0 {
+		return whole.String()
+	}
+	return whole.String() + "." + strings.TrimRight(fmt.Sprintf("%018s", frac.String()), "0")
+}
+
 // BIP39 Mnemonic support (simplified)
 func GenerateMnemonic() string {
 	// In production, use proper BIP39 implementation